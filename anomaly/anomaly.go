@@ -0,0 +1,224 @@
+package anomaly
+
+import (
+	"assignment/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Thresholds configures when a batch of a source is anomalous compared to its recent history
+type Thresholds struct {
+	// MinHistory is the number of batches needed before batches are checked
+	MinHistory int `json:"minHistory"`
+	// HistorySize is the number of recent batches a new batch is compared with
+	HistorySize int `json:"historySize"`
+	// MaxCountRatio flags item counts more than this many times above or below the typical count
+	MaxCountRatio float64 `json:"maxCountRatio"`
+	// MaxViewsRatio flags median views more than this many times above or below the typical median
+	MaxViewsRatio float64 `json:"maxViewsRatio"`
+	// MaxRelevanceShift flags mean relevance scores further than this from the typical mean
+	MaxRelevanceShift float64 `json:"maxRelevanceShift"`
+	// Quarantine serves the previous batch instead of an anomalous one
+	Quarantine bool `json:"quarantine"`
+	// MaxQuarantined is the number of consecutive quarantined batches after which
+	// the new batch is accepted as the new normal and the history restarts
+	MaxQuarantined int `json:"maxQuarantined"`
+}
+
+// DefaultThresholds flags zero views or 10x spikes without quarantining
+var DefaultThresholds = Thresholds{
+	MinHistory:        3,
+	HistorySize:       10,
+	MaxCountRatio:     3,
+	MaxViewsRatio:     10,
+	MaxRelevanceShift: 0.3,
+	Quarantine:        false,
+	MaxQuarantined:    10,
+}
+
+// UnmarshalJSON reads the thresholds, keeping the default of the fields not given
+func (t *Thresholds) UnmarshalJSON(b []byte) error {
+	type plain Thresholds
+	thresholds := plain(DefaultThresholds)
+	if err := json.Unmarshal(b, &thresholds); err != nil {
+		return err
+	}
+	*t = Thresholds(thresholds)
+	return nil
+}
+
+// Check validates the thresholds
+func (t Thresholds) Check() error {
+	if t.MinHistory < 1 {
+		return errors.New("anomaly 'minHistory' must be at least 1")
+	}
+	if t.HistorySize < t.MinHistory {
+		return errors.New("anomaly 'historySize' must be at least 'minHistory'")
+	}
+	if t.MaxCountRatio != 0 && t.MaxCountRatio <= 1 {
+		return errors.New("anomaly 'maxCountRatio' must be 0 or greater than 1")
+	}
+	if t.MaxViewsRatio != 0 && t.MaxViewsRatio <= 1 {
+		return errors.New("anomaly 'maxViewsRatio' must be 0 or greater than 1")
+	}
+	if t.MaxRelevanceShift < 0 {
+		return errors.New("anomaly 'maxRelevanceShift' must not be negative")
+	}
+	if t.MaxQuarantined < 0 {
+		return errors.New("anomaly 'maxQuarantined' must not be negative")
+	}
+	return nil
+}
+
+// Stats summarizes a batch of a source
+type Stats struct {
+	Count         int     `json:"count"`
+	MedianViews   float64 `json:"medianViews"`
+	MeanRelevance float64 `json:"meanRelevance"`
+}
+
+// Result is the outcome of inspecting a batch
+type Result struct {
+	Anomalous   bool     `json:"anomalous"`
+	Reasons     []string `json:"reasons,omitempty"`
+	Quarantined bool     `json:"quarantined"`
+	Stats       Stats    `json:"stats"`
+	Typical     *Stats   `json:"typical,omitempty"`
+}
+
+type history struct {
+	stats       []Stats
+	last        models.SiteData // last accepted batch
+	quarantined int             // consecutive quarantined batches
+}
+
+// Detector compares every batch of a source with its recent history
+type Detector struct {
+	Thresholds Thresholds
+
+	mu      sync.Mutex
+	sources map[string]*history
+}
+
+// NewDetector returns a detector without history using thresholds
+func NewDetector(thresholds Thresholds) *Detector {
+	return &Detector{
+		Thresholds: thresholds,
+		sources:    make(map[string]*history),
+	}
+}
+
+// Inspect checks a successfully fetched batch of source against its history.
+// It returns the data to serve, which is the previous batch when the new one
+// is anomalous and quarantined, and the result of the check.
+func (d *Detector) Inspect(source string, data models.SiteData) (models.SiteData, Result) {
+	result := Result{Stats: summarize(data.UrlData)}
+	if data.URLError != nil {
+		return data, result
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	h, ok := d.sources[source]
+	if !ok {
+		h = &history{}
+		d.sources[source] = h
+	}
+
+	if len(h.stats) >= d.Thresholds.MinHistory && len(h.stats) > 0 {
+		typical := median(h.stats)
+		result.Typical = &typical
+		result.Reasons = d.compare(result.Stats, typical)
+		result.Anomalous = len(result.Reasons) > 0
+	}
+
+	if result.Anomalous {
+		log.Println("Anomalous data from: ", source, result.Reasons)
+		if d.Thresholds.Quarantine && h.quarantined < d.Thresholds.MaxQuarantined {
+			h.quarantined++
+			result.Quarantined = true
+			return h.last, result
+		}
+		if d.Thresholds.Quarantine {
+			log.Println("Accepting data after too many quarantined batches from: ", source)
+			h.stats = nil
+		}
+	}
+
+	h.quarantined = 0
+	h.last = data
+	h.stats = append(h.stats, result.Stats)
+	if len(h.stats) > d.Thresholds.HistorySize {
+		h.stats = h.stats[len(h.stats)-d.Thresholds.HistorySize:]
+	}
+	return data, result
+}
+
+// compare returns the reasons why stats is anomalous compared to typical
+func (d *Detector) compare(stats, typical Stats) []string {
+	var reasons []string
+	if outOfRatio(float64(stats.Count), float64(typical.Count), d.Thresholds.MaxCountRatio) {
+		reasons = append(reasons, fmt.Sprintf("item count %d, typical %d", stats.Count, typical.Count))
+	}
+	if outOfRatio(stats.MedianViews, typical.MedianViews, d.Thresholds.MaxViewsRatio) {
+		reasons = append(reasons, fmt.Sprintf("median views %g, typical %g", stats.MedianViews, typical.MedianViews))
+	}
+	if d.Thresholds.MaxRelevanceShift > 0 && math.Abs(stats.MeanRelevance-typical.MeanRelevance) > d.Thresholds.MaxRelevanceShift {
+		reasons = append(reasons, fmt.Sprintf("mean relevanceScore %g, typical %g", stats.MeanRelevance, typical.MeanRelevance))
+	}
+	return reasons
+}
+
+// outOfRatio reports whether value is more than ratio times above or below typical
+func outOfRatio(value, typical, ratio float64) bool {
+	if ratio <= 0 || typical == 0 {
+		return false
+	}
+	return value > typical*ratio || value < typical/ratio
+}
+
+func summarize(data []models.UrlData) Stats {
+	stats := Stats{Count: len(data)}
+	if len(data) == 0 {
+		return stats
+	}
+	views := make([]float64, len(data))
+	for i, urlData := range data {
+		views[i] = float64(urlData.Views)
+		stats.MeanRelevance += urlData.RelevanceScore
+	}
+	stats.MedianViews = medianOf(views)
+	stats.MeanRelevance /= float64(len(data))
+	return stats
+}
+
+// median returns the per field median of the stats
+func median(stats []Stats) Stats {
+	counts := make([]float64, len(stats))
+	views := make([]float64, len(stats))
+	relevances := make([]float64, len(stats))
+	for i, s := range stats {
+		counts[i] = float64(s.Count)
+		views[i] = s.MedianViews
+		relevances[i] = s.MeanRelevance
+	}
+	return Stats{
+		Count:         int(math.Round(medianOf(counts))),
+		MedianViews:   medianOf(views),
+		MeanRelevance: medianOf(relevances),
+	}
+}
+
+func medianOf(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
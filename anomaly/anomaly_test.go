@@ -0,0 +1,128 @@
+package anomaly
+
+import (
+	"assignment/models"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func batch(n, views int, relevance float64) models.SiteData {
+	var data models.SiteData
+	for i := 0; i < n; i++ {
+		data.UrlData = append(data.UrlData, models.UrlData{
+			Url:            "www.example.com/abc",
+			Views:          views + i,
+			RelevanceScore: relevance,
+		})
+	}
+	return data
+}
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      models.SiteData
+		anomalous bool
+	}{
+		{"TestNormal", batch(5, 1050, 0.55), false},
+		{"TestZeroViews", batch(5, 0, 0.5), true},
+		{"TestViewsSpike", batch(5, 11000, 0.5), true},
+		{"TestFewItems", batch(1, 1000, 0.5), true},
+		{"TestRelevanceShift", batch(5, 1000, 0.95), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDetector(DefaultThresholds)
+			for i := 0; i < DefaultThresholds.MinHistory; i++ {
+				_, result := d.Inspect("source", batch(5, 1000, 0.5))
+				assert.False(t, result.Anomalous)
+			}
+			data, result := d.Inspect("source", tt.data)
+			assert.Equal(t, tt.anomalous, result.Anomalous)
+			assert.Equal(t, tt.anomalous, len(result.Reasons) > 0)
+			assert.False(t, result.Quarantined)
+			assert.Equal(t, tt.data, data)
+			assert.Equal(t, 5, result.Typical.Count)
+		})
+	}
+}
+
+func TestInspectNotEnoughHistory(t *testing.T) {
+	d := NewDetector(DefaultThresholds)
+	_, result := d.Inspect("source", batch(5, 1000, 0.5))
+	assert.False(t, result.Anomalous)
+	_, result = d.Inspect("source", batch(5, 0, 0.5))
+	assert.False(t, result.Anomalous)
+	assert.Nil(t, result.Typical)
+}
+
+func TestInspectIgnoresErrors(t *testing.T) {
+	d := NewDetector(DefaultThresholds)
+	for i := 0; i < DefaultThresholds.MinHistory; i++ {
+		d.Inspect("source", batch(5, 1000, 0.5))
+	}
+	failed := models.SiteData{URLError: errors.New("Some error")}
+	data, result := d.Inspect("source", failed)
+	assert.False(t, result.Anomalous)
+	assert.Equal(t, failed, data)
+}
+
+func TestInspectQuarantine(t *testing.T) {
+	thresholds := DefaultThresholds
+	thresholds.Quarantine = true
+	thresholds.MaxQuarantined = 2
+	d := NewDetector(thresholds)
+
+	good := batch(5, 1000, 0.5)
+	for i := 0; i < thresholds.MinHistory; i++ {
+		d.Inspect("source", good)
+	}
+	d.Inspect("other", batch(5, 0, 0.5))
+
+	bad := batch(5, 0, 0.5)
+	for i := 0; i < thresholds.MaxQuarantined; i++ {
+		data, result := d.Inspect("source", bad)
+		assert.True(t, result.Anomalous)
+		assert.True(t, result.Quarantined)
+		assert.Equal(t, good, data)
+	}
+
+	// accepted as the new normal after too many quarantined batches
+	data, result := d.Inspect("source", bad)
+	assert.True(t, result.Anomalous)
+	assert.False(t, result.Quarantined)
+	assert.Equal(t, bad, data)
+}
+
+func TestThresholdsJSON(t *testing.T) {
+	var thresholds Thresholds
+	assert.Nil(t, json.Unmarshal([]byte(`{"quarantine": true, "maxViewsRatio": 5, "maxRelevanceShift": 0}`), &thresholds))
+	expected := DefaultThresholds
+	expected.Quarantine = true
+	expected.MaxViewsRatio = 5
+	expected.MaxRelevanceShift = 0
+	assert.Equal(t, expected, thresholds)
+}
+
+func TestThresholdsCheck(t *testing.T) {
+	assert.Nil(t, DefaultThresholds.Check())
+	tests := []struct {
+		change func(t *Thresholds)
+		err    string
+	}{
+		{func(t *Thresholds) { t.MinHistory = 0 }, "anomaly 'minHistory' must be at least 1"},
+		{func(t *Thresholds) { t.HistorySize = 2 }, "anomaly 'historySize' must be at least 'minHistory'"},
+		{func(t *Thresholds) { t.MaxCountRatio = 0.5 }, "anomaly 'maxCountRatio' must be 0 or greater than 1"},
+		{func(t *Thresholds) { t.MaxViewsRatio = 1 }, "anomaly 'maxViewsRatio' must be 0 or greater than 1"},
+		{func(t *Thresholds) { t.MaxRelevanceShift = -1 }, "anomaly 'maxRelevanceShift' must not be negative"},
+		{func(t *Thresholds) { t.MaxQuarantined = -1 }, "anomaly 'maxQuarantined' must not be negative"},
+	}
+	for _, tt := range tests {
+		thresholds := DefaultThresholds
+		tt.change(&thresholds)
+		assert.EqualError(t, thresholds.Check(), tt.err)
+	}
+}
@@ -0,0 +1,364 @@
+// Package config reads the configuration of the server from its defaults, a
+// JSON config file, environment variables and command-line flags, in order of
+// increasing precedence.
+package config
+
+import (
+	"assignment/httprequest"
+	"assignment/tracing"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the configuration of the server
+type Config struct {
+	// Addr is the address the server listens on
+	Addr string `json:"addr"`
+	// SourcesFile lists the sources, by default the three urls of the assignment are queried
+	SourcesFile string `json:"sourcesFile"`
+	// SnapshotsDir stores the snapshots, kept for SnapshotMaxAge and at most SnapshotMaxCount (0 for no limit)
+	SnapshotsDir     string               `json:"snapshotsDir"`
+	SnapshotMaxAge   httprequest.Duration `json:"snapshotMaxAge"`
+	SnapshotMaxCount int                  `json:"snapshotMaxCount"`
+	// RefreshInterval is the time between two background refreshes
+	RefreshInterval httprequest.Duration `json:"refreshInterval"`
+	// MaxLimit is the largest 'limit' accepted by getData
+	MaxLimit int `json:"maxLimit"`
+	// MaxWebhooks is the largest number of webhooks, registered for the WebhookHosts
+	// only when set, or else for any host with a public address
+	MaxWebhooks  int      `json:"maxWebhooks"`
+	WebhookHosts []string `json:"webhookHosts"`
+	// MinReadySources is the number of sources fetched successfully before the server is ready
+	MinReadySources int `json:"minReadySources"`
+	// UpstreamTimeout limits each request to a source, UpstreamRetries is the number of attempts of a fetch
+	UpstreamTimeout httprequest.Duration `json:"upstreamTimeout"`
+	UpstreamRetries int                  `json:"upstreamRetries"`
+	// The timeouts of the HTTP server
+	ReadHeaderTimeout httprequest.Duration `json:"readHeaderTimeout"`
+	ReadTimeout       httprequest.Duration `json:"readTimeout"`
+	WriteTimeout      httprequest.Duration `json:"writeTimeout"`
+	IdleTimeout       httprequest.Duration `json:"idleTimeout"`
+	// RequestTimeout limits the fetches of a getData request. It is below WriteTimeout
+	// so that a slow fetch gets an error response rather than a cut off one.
+	RequestTimeout httprequest.Duration `json:"requestTimeout"`
+	// ShutdownTimeout is how long in-flight requests and deliveries are drained on shutdown
+	ShutdownTimeout httprequest.Duration `json:"shutdownTimeout"`
+	// TraceExporter exports the spans to stdout or to the OTLP/HTTP collector at
+	// TraceEndpoint, TraceSampleRatio is the fraction of the traces sampled
+	TraceExporter    string  `json:"traceExporter"`
+	TraceEndpoint    string  `json:"traceEndpoint"`
+	TraceSampleRatio float64 `json:"traceSampleRatio"`
+}
+
+// Default returns the configuration used when nothing is set
+func Default() Config {
+	return Config{
+		Addr:              ":8000",
+		SnapshotsDir:      "snapshots",
+		SnapshotMaxAge:    httprequest.Duration(7 * 24 * time.Hour),
+		RefreshInterval:   httprequest.Duration(time.Minute),
+		MaxLimit:          200,
+		MaxWebhooks:       100,
+		MinReadySources:   1,
+		UpstreamTimeout:   httprequest.Duration(2 * time.Second),
+		UpstreamRetries:   3,
+		ReadHeaderTimeout: httprequest.Duration(5 * time.Second),
+		ReadTimeout:       httprequest.Duration(10 * time.Second),
+		WriteTimeout:      httprequest.Duration(30 * time.Second),
+		IdleTimeout:       httprequest.Duration(2 * time.Minute),
+		RequestTimeout:    httprequest.Duration(25 * time.Second),
+		ShutdownTimeout:   httprequest.Duration(20 * time.Second),
+		TraceExporter:     tracing.ExporterNone,
+		TraceSampleRatio:  1,
+	}
+}
+
+// option is a setting available as a flag and an environment variable
+type option struct {
+	flag, env, usage string
+	set              func(c *Config, value string) error
+}
+
+func options() []option {
+	return []option{
+		{"addr", "ASSIGNMENT_LISTEN_ADDR", "address the server listens on", setString(func(c *Config) *string { return &c.Addr })},
+		{"sources-file", "ASSIGNMENT_SOURCES_FILE", "JSON file listing the sources", setString(func(c *Config) *string { return &c.SourcesFile })},
+		{"snapshots-dir", "ASSIGNMENT_SNAPSHOTS_DIR", "directory of the snapshots", setString(func(c *Config) *string { return &c.SnapshotsDir })},
+		{"snapshot-max-age", "ASSIGNMENT_SNAPSHOT_MAX_AGE", "how long snapshots are kept", setDuration(func(c *Config) *httprequest.Duration { return &c.SnapshotMaxAge })},
+		{"snapshot-max-count", "ASSIGNMENT_SNAPSHOT_MAX_COUNT", "maximum number of snapshots kept, 0 for no limit", setInt(func(c *Config) *int { return &c.SnapshotMaxCount })},
+		{"refresh-interval", "ASSIGNMENT_REFRESH_INTERVAL", "time between two background refreshes", setDuration(func(c *Config) *httprequest.Duration { return &c.RefreshInterval })},
+		{"max-limit", "ASSIGNMENT_MAX_LIMIT", "largest 'limit' accepted by getData", setInt(func(c *Config) *int { return &c.MaxLimit })},
+		{"max-webhooks", "ASSIGNMENT_MAX_WEBHOOKS", "largest number of webhooks", setInt(func(c *Config) *int { return &c.MaxWebhooks })},
+		{"webhook-hosts", "ASSIGNMENT_WEBHOOK_HOSTS", "comma separated hosts webhooks can be registered for, private ones included", setList(func(c *Config) *[]string { return &c.WebhookHosts })},
+		{"min-ready-sources", "ASSIGNMENT_MIN_READY_SOURCES", "sources fetched successfully before the server is ready", setInt(func(c *Config) *int { return &c.MinReadySources })},
+		{"upstream-timeout", "ASSIGNMENT_UPSTREAM_TIMEOUT", "timeout of each request to a source", setDuration(func(c *Config) *httprequest.Duration { return &c.UpstreamTimeout })},
+		{"upstream-retries", "ASSIGNMENT_UPSTREAM_RETRIES", "number of attempts to fetch a source", setInt(func(c *Config) *int { return &c.UpstreamRetries })},
+		{"read-header-timeout", "ASSIGNMENT_READ_HEADER_TIMEOUT", "timeout to read the request headers", setDuration(func(c *Config) *httprequest.Duration { return &c.ReadHeaderTimeout })},
+		{"read-timeout", "ASSIGNMENT_READ_TIMEOUT", "timeout to read a request", setDuration(func(c *Config) *httprequest.Duration { return &c.ReadTimeout })},
+		{"write-timeout", "ASSIGNMENT_WRITE_TIMEOUT", "timeout to write a response", setDuration(func(c *Config) *httprequest.Duration { return &c.WriteTimeout })},
+		{"idle-timeout", "ASSIGNMENT_IDLE_TIMEOUT", "how long idle connections are kept open", setDuration(func(c *Config) *httprequest.Duration { return &c.IdleTimeout })},
+		{"request-timeout", "ASSIGNMENT_REQUEST_TIMEOUT", "timeout of the fetches of a getData request, below the write timeout", setDuration(func(c *Config) *httprequest.Duration { return &c.RequestTimeout })},
+		{"shutdown-timeout", "ASSIGNMENT_SHUTDOWN_TIMEOUT", "how long requests are drained on shutdown", setDuration(func(c *Config) *httprequest.Duration { return &c.ShutdownTimeout })},
+		{"trace-exporter", "ASSIGNMENT_TRACE_EXPORTER", "exporter of the spans: none, stdout or otlp", setString(func(c *Config) *string { return &c.TraceExporter })},
+		{"trace-endpoint", "ASSIGNMENT_TRACE_ENDPOINT", "url of the OTLP/HTTP collector", setString(func(c *Config) *string { return &c.TraceEndpoint })},
+		{"trace-sample-ratio", "ASSIGNMENT_TRACE_SAMPLE_RATIO", "fraction of the traces sampled", setFloat(func(c *Config) *float64 { return &c.TraceSampleRatio })},
+	}
+}
+
+func setString(field func(c *Config) *string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func setInt(field func(c *Config) *int) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("invalid integer: " + value)
+		}
+		*field(c) = i
+		return nil
+	}
+}
+
+func setFloat(field func(c *Config) *float64) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.New("invalid number: " + value)
+		}
+		*field(c) = f
+		return nil
+	}
+}
+
+func setList(field func(c *Config) *[]string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*field(c) = list
+		return nil
+	}
+}
+
+func setDuration(field func(c *Config) *httprequest.Duration) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("invalid duration: " + value)
+		}
+		*field(c) = httprequest.Duration(d)
+		return nil
+	}
+}
+
+// Load reads the configuration from the defaults, then the config file given
+// by the -config flag or ASSIGNMENT_CONFIG_FILE, then the environment variables, then the
+// other flags of args. It also reports whether -print-config was given.
+func Load(args []string, output io.Writer) (Config, bool, error) {
+	cfg := Default()
+	fs := flag.NewFlagSet("assignment", flag.ContinueOnError)
+	fs.SetOutput(output)
+	configFile := fs.String("config", os.Getenv("ASSIGNMENT_CONFIG_FILE"), "JSON config file (env ASSIGNMENT_CONFIG_FILE)")
+	printConfig := fs.Bool("print-config", false, "print the effective configuration and exit")
+
+	flags := map[string]string{}
+	for _, o := range options() {
+		name := o.flag
+		fs.Func(name, o.usage+" (env "+o.env+")", func(value string) error {
+			flags[name] = value
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, false, err
+	}
+	if fs.NArg() > 0 {
+		return cfg, false, errors.New("unexpected arguments: " + strings.Join(fs.Args(), " "))
+	}
+
+	if *configFile != "" {
+		if err := readFile(*configFile, &cfg); err != nil {
+			return cfg, false, err
+		}
+	}
+	for _, o := range options() {
+		if value, ok := os.LookupEnv(o.env); ok {
+			if err := o.set(&cfg, value); err != nil {
+				return cfg, false, errors.New(o.env + ": " + err.Error())
+			}
+		}
+	}
+	for _, o := range options() {
+		if value, ok := flags[o.flag]; ok {
+			if err := o.set(&cfg, value); err != nil {
+				return cfg, false, errors.New("-" + o.flag + ": " + err.Error())
+			}
+		}
+	}
+	return cfg, *printConfig, cfg.Validate()
+}
+
+func readFile(path string, cfg *Config) error {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return errors.New("Error while parsing config file: " + err.Error())
+	}
+	return nil
+}
+
+// Validate checks that the configuration can be used
+func (c Config) Validate() error {
+	if c.Addr == "" {
+		return errors.New("config 'addr' is missing")
+	}
+	if c.SnapshotsDir == "" {
+		return errors.New("config 'snapshotsDir' is missing")
+	}
+	durations := []struct {
+		name  string
+		value httprequest.Duration
+	}{
+		{"snapshotMaxAge", c.SnapshotMaxAge},
+		{"refreshInterval", c.RefreshInterval},
+		{"upstreamTimeout", c.UpstreamTimeout},
+		{"readHeaderTimeout", c.ReadHeaderTimeout},
+		{"readTimeout", c.ReadTimeout},
+		{"writeTimeout", c.WriteTimeout},
+		{"idleTimeout", c.IdleTimeout},
+		{"requestTimeout", c.RequestTimeout},
+		{"shutdownTimeout", c.ShutdownTimeout},
+	}
+	for _, d := range durations {
+		if d.value <= 0 {
+			return errors.New("config '" + d.name + "' must be positive")
+		}
+	}
+	if c.RequestTimeout >= c.WriteTimeout {
+		return errors.New("config 'requestTimeout' must be less than 'writeTimeout'")
+	}
+	if c.SnapshotMaxCount < 0 {
+		return errors.New("config 'snapshotMaxCount' must not be negative")
+	}
+	if c.MaxLimit < 1 {
+		return errors.New("config 'maxLimit' must be at least 1")
+	}
+	if c.MaxWebhooks < 1 {
+		return errors.New("config 'maxWebhooks' must be at least 1")
+	}
+	if c.MinReadySources < 0 {
+		return errors.New("config 'minReadySources' must not be negative")
+	}
+	if c.UpstreamRetries < 1 {
+		return errors.New("config 'upstreamRetries' must be at least 1")
+	}
+	if err := c.Tracing().Check(); err != nil {
+		return errors.New("config: " + err.Error())
+	}
+	return nil
+}
+
+// CheckSources checks the configuration against the sources queried by getData
+func (c Config) CheckSources(sources []httprequest.Source) error {
+	if c.MinReadySources > len(sources) {
+		return errors.New("config 'minReadySources' must not exceed the number of sources: " + strconv.Itoa(len(sources)))
+	}
+	return nil
+}
+
+// Tracing returns the options of the span exporter
+func (c Config) Tracing() tracing.Options {
+	return tracing.Options{
+		Exporter:    c.TraceExporter,
+		Endpoint:    c.TraceEndpoint,
+		SampleRatio: c.TraceSampleRatio,
+	}
+}
+
+// Print writes the configuration and the sources as JSON, without the
+// credentials of their urls, headers and query parameters
+func Print(w io.Writer, cfg Config, sources []httprequest.Source) error {
+	cfg.TraceEndpoint = redactURL(cfg.TraceEndpoint)
+	redactedSources := make([]httprequest.Source, len(sources))
+	for i, source := range sources {
+		redactedSources[i] = redactSource(source)
+	}
+	body, err := json.MarshalIndent(struct {
+		Config  Config               `json:"config"`
+		Sources []httprequest.Source `json:"sources"`
+	}{cfg, redactedSources}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(body))
+	return err
+}
+
+func redactSource(source httprequest.Source) httprequest.Source {
+	source.URL = redactURL(source.URL)
+	if source.Mirrors != nil {
+		mirrors := make([]string, len(source.Mirrors))
+		for i, mirror := range source.Mirrors {
+			mirrors[i] = redactURL(mirror)
+		}
+		source.Mirrors = mirrors
+	}
+	source.Proxy.URL = redactURL(source.Proxy.URL)
+	source.Headers = redactValues(source.Headers)
+	source.Query = redactValues(source.Query)
+	return source
+}
+
+// redactURL hides the password and the sensitive query parameters of a url.
+// A url that does not parse is hidden entirely.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return httprequest.Redacted
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for name := range query {
+			if httprequest.Sensitive(name) {
+				query.Set(name, httprequest.Redacted)
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.Redacted()
+}
+
+func redactValues(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(values))
+	for name, value := range values {
+		copied[name] = value
+		if httprequest.Sensitive(name) {
+			copied[name] = httprequest.Redacted
+		}
+	}
+	return copied
+}
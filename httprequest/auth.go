@@ -0,0 +1,262 @@
+package httprequest
+
+import (
+	"assignment/metrics"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenExpiryMargin is how long before its expiry an OAuth2 token is refreshed
+const tokenExpiryMargin = 30 * time.Second
+
+// Auth configures how the requests to a source are authenticated
+type Auth struct {
+	// Type is one of "bearer", "basic", "apiKey" or "oauth2"
+	Type string `json:"type"`
+	// Token is the bearer token
+	Token Secret `json:"token"`
+	// Username and Password are the basic auth credentials
+	Username string `json:"username"`
+	Password Secret `json:"password"`
+	// Key is the API key, sent in the Header or else as the Query parameter
+	Key    Secret `json:"key"`
+	Header string `json:"header"`
+	Query  string `json:"query"`
+	// TokenURL, ClientID, ClientSecret and Scopes get OAuth2 tokens with the client credentials grant
+	TokenURL     string   `json:"tokenUrl"`
+	ClientID     string   `json:"clientId"`
+	ClientSecret Secret   `json:"clientSecret"`
+	Scopes       []string `json:"scopes"`
+}
+
+// Secret is a credential read from an environment variable or a file when a
+// request is made, so it can be rotated without a restart. Only the reference
+// is kept, the value is never logged nor written out.
+type Secret struct {
+	Env  string `json:"env,omitempty"`
+	File string `json:"file,omitempty"`
+}
+
+// Value reads the secret
+func (s Secret) Value() (string, error) {
+	switch {
+	case s.Env != "":
+		value, ok := os.LookupEnv(s.Env)
+		if !ok {
+			return "", errors.New("secret environment variable is not set: " + s.Env)
+		}
+		return value, nil
+	case s.File != "":
+		body, err := ioutil.ReadFile(s.File)
+		if err != nil {
+			return "", errors.New("Error while reading secret file: " + s.File)
+		}
+		return strings.TrimSpace(string(body)), nil
+	}
+	return "", errors.New("secret is missing")
+}
+
+// String hides the secret from logs
+func (s Secret) String() string {
+	return "[redacted]"
+}
+
+func (s Secret) check(name string) error {
+	if (s.Env == "") == (s.File == "") {
+		return errors.New("auth '" + name + "' needs exactly one of 'env' or 'file'")
+	}
+	return nil
+}
+
+func (a Auth) check() error {
+	switch a.Type {
+	case "":
+		return nil
+	case "bearer":
+		return a.Token.check("token")
+	case "basic":
+		if a.Username == "" {
+			return errors.New("auth 'username' is missing")
+		}
+		return a.Password.check("password")
+	case "apiKey":
+		if (a.Header == "") == (a.Query == "") {
+			return errors.New("auth needs exactly one of 'header' or 'query'")
+		}
+		return a.Key.check("key")
+	case "oauth2":
+		if a.TokenURL == "" {
+			return errors.New("auth 'tokenUrl' is missing")
+		}
+		if a.ClientID == "" {
+			return errors.New("auth 'clientId' is missing")
+		}
+		return a.ClientSecret.check("clientSecret")
+	}
+	return errors.New("unknown auth type: " + a.Type)
+}
+
+// authenticate adds the credentials of the source to req
+func (api *API) authenticate(req *http.Request) error {
+	auth := api.Source.Auth
+	switch auth.Type {
+	case "bearer":
+		token, err := auth.Token.Value()
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case "basic":
+		password, err := auth.Password.Value()
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(auth.Username, password)
+	case "apiKey":
+		key, err := auth.Key.Value()
+		if err != nil {
+			return err
+		}
+		if auth.Header != "" {
+			req.Header.Set(auth.Header, key)
+			break
+		}
+		query := req.URL.Query()
+		query.Set(auth.Query, key)
+		req.URL.RawQuery = query.Encode()
+	case "oauth2":
+		token, err := tokens.get(req.Context(), api.Client, auth)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+// token is a cached OAuth2 access token
+type token struct {
+	value  string
+	expiry time.Time // zero when the token does not expire
+}
+
+// tokens caches the OAuth2 tokens by token url and client id
+var tokens = tokenCache{m: make(map[string]*tokenEntry)}
+
+type tokenCache struct {
+	sync.Mutex
+	m map[string]*tokenEntry
+}
+
+// tokenEntry is the token of a token url and client id. Its lock is held while
+// the token is requested, so the sources sharing it wait for a single request
+// and the other sources are not blocked.
+type tokenEntry struct {
+	sync.Mutex
+	token token
+	ok    bool
+}
+
+func tokenKey(auth Auth) string {
+	return auth.TokenURL + " " + auth.ClientID
+}
+
+// entry returns the entry of auth, adding it when missing
+func (c *tokenCache) entry(auth Auth) *tokenEntry {
+	c.Lock()
+	defer c.Unlock()
+	key := tokenKey(auth)
+	entry, ok := c.m[key]
+	if !ok {
+		entry = &tokenEntry{}
+		c.m[key] = entry
+	}
+	return entry
+}
+
+// get returns the cached token of auth, requesting a new one when it is
+// missing or about to expire. The request is bound to ctx, so a canceled
+// source does not hold the lock of the entry.
+func (c *tokenCache) get(ctx context.Context, client *http.Client, auth Auth) (string, error) {
+	entry := c.entry(auth)
+	entry.Lock()
+	defer entry.Unlock()
+	hit := entry.ok && (entry.token.expiry.IsZero() || timeNow().Before(entry.token.expiry.Add(-tokenExpiryMargin)))
+	metrics.CacheLookup("token", hit)
+	if hit {
+		return entry.token.value, nil
+	}
+	fetched, err := requestToken(ctx, client, auth)
+	if err != nil {
+		log.Println("Error while requesting oauth2 token: ", auth.TokenURL, err)
+		return "", err
+	}
+	entry.token, entry.ok = fetched, true
+	return fetched.value, nil
+}
+
+// invalidate drops the cached token of auth after it was rejected
+func (c *tokenCache) invalidate(auth Auth) {
+	entry := c.entry(auth)
+	entry.Lock()
+	defer entry.Unlock()
+	entry.token, entry.ok = token{}, false
+}
+
+// requestToken gets a token from the token endpoint with the client credentials grant
+func requestToken(ctx context.Context, client *http.Client, auth Auth) (token, error) {
+	secret, err := auth.ClientSecret.Value()
+	if err != nil {
+		return token{}, err
+	}
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(auth.Scopes) > 0 {
+		form.Set("scope", strings.Join(auth.Scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, auth.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return token{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(auth.ClientID), url.QueryEscape(secret))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return token{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return token{}, fmt.Errorf("token endpoint returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(&limitedReader{r: resp.Body, max: 1 << 20}).Decode(&body); err != nil {
+		return token{}, errors.New("Error while parsing token response: " + err.Error())
+	}
+	if body.AccessToken == "" {
+		return token{}, errors.New("token response has no access_token")
+	}
+	if body.TokenType != "" && !strings.EqualFold(body.TokenType, "bearer") {
+		return token{}, errors.New("unsupported token type: " + body.TokenType)
+	}
+	fetched := token{value: body.AccessToken}
+	if body.ExpiresIn > 0 {
+		fetched.expiry = timeNow().Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+	return fetched, nil
+}
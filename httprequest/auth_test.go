@@ -0,0 +1,246 @@
+package httprequest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const authBody = `{"data": [{"url": "www.example.com/abc1", "views": 1000, "relevanceScore": 0.1}]}`
+
+// authServer serves authBody to the requests accepted by authorized
+func authServer(t *testing.T, authorized func(req *http.Request) bool) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if !authorized(req) {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		rw.Write([]byte(authBody))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAuthenticate(t *testing.T) {
+	os.Setenv("TEST_AUTH_SECRET", "s3cret")
+	defer os.Unsetenv("TEST_AUTH_SECRET")
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	writeFile(t, secretFile, "s3cret\n")
+
+	tests := []struct {
+		name       string
+		auth       Auth
+		authorized func(req *http.Request) bool
+	}{
+		{"bearer", Auth{Type: "bearer", Token: Secret{Env: "TEST_AUTH_SECRET"}}, func(req *http.Request) bool {
+			return req.Header.Get("Authorization") == "Bearer s3cret"
+		}},
+		{"basic", Auth{Type: "basic", Username: "user", Password: Secret{File: secretFile}}, func(req *http.Request) bool {
+			username, password, ok := req.BasicAuth()
+			return ok && username == "user" && password == "s3cret"
+		}},
+		{"apiKey header", Auth{Type: "apiKey", Header: "X-Api-Key", Key: Secret{Env: "TEST_AUTH_SECRET"}}, func(req *http.Request) bool {
+			return req.Header.Get("X-Api-Key") == "s3cret"
+		}},
+		{"apiKey query", Auth{Type: "apiKey", Query: "api_key", Key: Secret{Env: "TEST_AUTH_SECRET"}}, func(req *http.Request) bool {
+			return req.URL.Query().Get("api_key") == "s3cret" && req.URL.Query().Get("page") == "1"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := authServer(t, tt.authorized)
+			api := API{Client: server.Client(), BaseURL: server.URL + "/?page=1", Source: Source{Auth: tt.auth}}
+			data, err := api.fetch(context.Background())
+			assert.Nil(t, err)
+			assert.Len(t, data.UrlData, 1)
+		})
+	}
+}
+
+func TestAuthenticateMissingSecret(t *testing.T) {
+	server := authServer(t, func(req *http.Request) bool { return true })
+	api := API{Client: server.Client(), BaseURL: server.URL, Source: Source{
+		Auth: Auth{Type: "bearer", Token: Secret{Env: "TEST_AUTH_MISSING"}},
+	}}
+	_, err := api.fetch(context.Background())
+	assert.EqualError(t, err, "secret environment variable is not set: TEST_AUTH_MISSING")
+}
+
+func TestAuthenticateSecretNotLogged(t *testing.T) {
+	os.Setenv("TEST_AUTH_SECRET", "s3cret")
+	defer os.Unsetenv("TEST_AUTH_SECRET")
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	server.Close()
+	api := API{Client: server.Client(), BaseURL: server.URL, Source: Source{
+		Auth: Auth{Type: "apiKey", Query: "api_key", Key: Secret{Env: "TEST_AUTH_SECRET"}},
+	}}
+	_, err := api.fetch(context.Background())
+	assert.NotNil(t, err)
+	assert.NotContains(t, err.Error(), "s3cret")
+	assert.NotContains(t, logs.String(), "s3cret")
+}
+
+func TestAuthenticateOAuth2(t *testing.T) {
+	os.Setenv("TEST_AUTH_CLIENT_SECRET", "client-s3cret")
+	defer os.Unsetenv("TEST_AUTH_CLIENT_SECRET")
+	defer func() { timeNow = time.Now }()
+	start := time.Now()
+	timeNow = func() time.Time { return start }
+
+	var issued, revoked int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		clientID, secret, _ := req.BasicAuth()
+		assert.Equal(t, "client", clientID)
+		assert.Equal(t, "client-s3cret", secret)
+		assert.Equal(t, "client_credentials", req.FormValue("grant_type"))
+		assert.Equal(t, "read write", req.FormValue("scope"))
+		n := atomic.AddInt32(&issued, 1)
+		json.NewEncoder(rw).Encode(map[string]interface{}{
+			"access_token": fmt.Sprint("token", n),
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	}))
+	defer tokenServer.Close()
+	server := authServer(t, func(req *http.Request) bool {
+		return req.Header.Get("Authorization") != fmt.Sprint("Bearer token", atomic.LoadInt32(&revoked)) &&
+			req.Header.Get("Authorization") != ""
+	})
+
+	auth := Auth{
+		Type:         "oauth2",
+		TokenURL:     tokenServer.URL,
+		ClientID:     "client",
+		ClientSecret: Secret{Env: "TEST_AUTH_CLIENT_SECRET"},
+		Scopes:       []string{"read", "write"},
+	}
+	defer tokens.invalidate(auth)
+	api := API{Client: server.Client(), BaseURL: server.URL, Source: Source{Auth: auth}}
+
+	// the token is cached
+	for i := 0; i < 2; i++ {
+		_, err := api.fetch(context.Background())
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&issued))
+
+	// and refreshed before it expires
+	timeNow = func() time.Time { return start.Add(time.Hour - tokenExpiryMargin) }
+	_, err := api.fetch(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&issued))
+
+	// a rejected token is dropped
+	atomic.StoreInt32(&revoked, 2)
+	_, err = api.fetch(context.Background())
+	assert.NotNil(t, err)
+	_, err = api.fetch(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&issued))
+}
+
+func TestAuthCheck(t *testing.T) {
+	tests := []struct {
+		auth Auth
+		err  string
+	}{
+		{Auth{}, ""},
+		{Auth{Type: "bearer", Token: Secret{Env: "TOKEN"}}, ""},
+		{Auth{Type: "bearer"}, "auth 'token' needs exactly one of 'env' or 'file'"},
+		{Auth{Type: "bearer", Token: Secret{Env: "TOKEN", File: "token"}}, "auth 'token' needs exactly one of 'env' or 'file'"},
+		{Auth{Type: "basic", Password: Secret{Env: "PASSWORD"}}, "auth 'username' is missing"},
+		{Auth{Type: "apiKey", Key: Secret{Env: "KEY"}}, "auth needs exactly one of 'header' or 'query'"},
+		{Auth{Type: "oauth2", ClientID: "client", ClientSecret: Secret{Env: "SECRET"}}, "auth 'tokenUrl' is missing"},
+		{Auth{Type: "digest"}, "unknown auth type: digest"},
+	}
+	for _, tt := range tests {
+		err := tt.auth.check()
+		if tt.err == "" {
+			assert.Nil(t, err)
+		} else {
+			assert.EqualError(t, err, tt.err)
+		}
+	}
+}
+
+func TestSecretRedacted(t *testing.T) {
+	secret := Secret{Env: "TOKEN"}
+	assert.Equal(t, "[redacted]", fmt.Sprint(secret))
+	body, err := json.Marshal(Auth{Type: "bearer", Token: secret})
+	assert.Nil(t, err)
+	assert.Contains(t, string(body), `"token":{"env":"TOKEN"}`)
+}
+
+func TestTokenCacheSlowEndpoint(t *testing.T) {
+	os.Setenv("TEST_AUTH_CLIENT_SECRET", "client-s3cret")
+	defer os.Unsetenv("TEST_AUTH_CLIENT_SECRET")
+	requested, release := make(chan struct{}), make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		close(requested)
+		<-release
+		rw.Write([]byte(`{"access_token": "slow", "expires_in": 3600}`))
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`{"access_token": "fast", "expires_in": 3600}`))
+	}))
+	defer fast.Close()
+
+	slowAuth := Auth{Type: "oauth2", TokenURL: slow.URL, ClientID: "client", ClientSecret: Secret{Env: "TEST_AUTH_CLIENT_SECRET"}}
+	fastAuth := Auth{Type: "oauth2", TokenURL: fast.URL, ClientID: "client", ClientSecret: Secret{Env: "TEST_AUTH_CLIENT_SECRET"}}
+	defer tokens.invalidate(slowAuth)
+	defer tokens.invalidate(fastAuth)
+
+	done := make(chan string)
+	go func() {
+		value, _ := tokens.get(context.Background(), http.DefaultClient, slowAuth)
+		done <- value
+	}()
+	<-requested
+
+	// the other token endpoints are not blocked by the slow one
+	value, err := tokens.get(context.Background(), http.DefaultClient, fastAuth)
+	assert.Nil(t, err)
+	assert.Equal(t, "fast", value)
+
+	close(release)
+	assert.Equal(t, "slow", <-done)
+}
+
+func TestTokenCacheCanceled(t *testing.T) {
+	os.Setenv("TEST_AUTH_CLIENT_SECRET", "client-s3cret")
+	defer os.Unsetenv("TEST_AUTH_CLIENT_SECRET")
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	auth := Auth{Type: "oauth2", TokenURL: slow.URL, ClientID: "client", ClientSecret: Secret{Env: "TEST_AUTH_CLIENT_SECRET"}}
+	defer tokens.invalidate(auth)
+
+	// the token request ends with the context of the source
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := tokens.get(ctx, http.DefaultClient, auth)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
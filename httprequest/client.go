@@ -1,62 +1,141 @@
 package httprequest
 
 import (
+	"assignment/anomaly"
+	"assignment/metrics"
 	"assignment/models"
-	"encoding/json"
+	"context"
 	"errors"
-	"io/ioutil"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
-	retries = 3 // default max retry count
+	retries        = 3               // default max retry count
+	requestTimeout = 2 * time.Second // default timeout of a request
+)
+
+// Retries is the number of attempts of a fetch and Timeout limits each of them
+var (
+	Retries = retries
+	Timeout = requestTimeout
 )
 
+// backoff is the delay before the first retry of a fetch, doubled before each next one
+var backoff = 2 * time.Second
+
 type API struct {
 	Client  *http.Client
 	BaseURL string
+	Source  Source
 }
 
 var GetContent = getContent
 
-// getContent executes GET request with url and writes response on channel
-func getContent(url string, siteData chan models.SiteData, wg *sync.WaitGroup) {
-	defer wg.Done()
-	client := http.Client{
-		Timeout: 2 * time.Second,
-	}
+// detectors keeps the anomaly detector of each source by name, which checks
+// every fetched batch against the recent history of the source
+var detectors = struct {
+	sync.Mutex
+	m map[string]*anomaly.Detector
+}{m: make(map[string]*anomaly.Detector)}
 
-	api := API{
-		Client:  &client,
-		BaseURL: url,
+// detectorFor returns the anomaly detector of the source, started over
+// without history when the thresholds of the source change
+func detectorFor(source Source) *anomaly.Detector {
+	thresholds := source.anomalyThresholds()
+	detectors.Lock()
+	defer detectors.Unlock()
+	detector, ok := detectors.m[source.Name]
+	if !ok || detector.Thresholds != thresholds {
+		detector = anomaly.NewDetector(thresholds)
+		detectors.m[source.Name] = detector
 	}
-	data := api.ExecuteAPI()
+	return detector
+}
+
+// getContent executes the request of the source, validates the items
+// and writes response on channel. Concurrent calls for the same source share
+// a single fetch.
+func getContent(ctx context.Context, source Source, siteData chan models.SiteData, wg *sync.WaitGroup) {
+	defer wg.Done()
+	ctx, span := tracer.Start(ctx, "fetch "+source.Name, trace.WithAttributes(attribute.String("source.name", source.Name)))
+	data := coalesce(ctx, source.Name, func(ctx context.Context) models.SiteData {
+		var data models.SiteData
+		client, err := clientFor(source)
+		if err != nil {
+			log.Println("Error while configuring transport: ", source.Name, err)
+			data.URLError = err
+		} else {
+			api := API{
+				Client:  client,
+				BaseURL: source.URL,
+				Source:  source,
+			}
+			start := time.Now()
+			data = api.ExecuteAPIContext(ctx)
+			metrics.FetchDuration.WithLabelValues(source.Name).Observe(time.Since(start).Seconds())
+		}
+		report := validate(source.Validation, &data)
+		data, result := detectorFor(source).Inspect(source.Name, data)
+		recordStatus(source, data, report, result)
+		if data.URLError == nil {
+			metrics.Items.WithLabelValues(source.Name).Set(float64(len(data.UrlData)))
+		}
+		return data
+	})
+	span.SetAttributes(attribute.Int("source.items", len(data.UrlData)))
+	endSpan(span, data.URLError)
 	siteData <- data
 }
 
-// ExecuteAPI makes get request to the api and returns response
+// ExecuteAPI makes the source request to the api and returns response
 func (api *API) ExecuteAPI() models.SiteData {
+	return api.ExecuteAPIContext(context.Background())
+}
+
+// ExecuteAPIContext is ExecuteAPI, giving up on the request and the retries when ctx is done
+func (api *API) ExecuteAPIContext(ctx context.Context) models.SiteData {
 	var data models.SiteData
-	sleep := 2 * time.Second
+	sleep := backoff
 
-	for i := 0; i < retries; i++ {
+	for i := 0; i < Retries; i++ {
 		if i > 0 {
 			log.Println("Retrying after error: ", api.BaseURL, data.URLError)
-			time.Sleep(sleep)
+			metrics.Retries.WithLabelValues(api.Source.Name).Inc()
+			trace.SpanFromContext(ctx).AddEvent("backoff", trace.WithAttributes(
+				attribute.String("backoff.delay", sleep.String()),
+				attribute.String("backoff.error", data.URLError.Error()),
+			))
+			select {
+			case <-ctx.Done():
+				data.URLError = ctx.Err()
+				return data
+			case <-time.After(sleep):
+			}
 			sleep *= 2
 		}
-		resp, err := api.Client.Get(api.BaseURL)
-		if err != nil {
-			log.Println("Error while making http request: ", api.BaseURL, err)
+		attemptCtx, span := tracer.Start(ctx, "attempt", trace.WithAttributes(attribute.Int("attempt", i+1)))
+		var err error
+		data, err = api.fetchMirrors(attemptCtx)
+		endSpan(span, err)
+		if errors.Is(err, ErrPayloadTooLarge) {
+			// the payload will not shrink on retry
 			data.URLError = err
-			continue
+			return data
 		}
-		data, err = parseResponse(api.BaseURL, resp)
 		if err != nil {
 			data.URLError = err
+			if ctx.Err() != nil {
+				return data
+			}
 			continue
 		}
 
@@ -66,24 +145,128 @@ func (api *API) ExecuteAPI() models.SiteData {
 	return data
 }
 
-func parseResponse(url string, resp *http.Response) (models.SiteData, error) {
+// fetch gets and parses BaseURL once, reading file urls from the local filesystem
+func (api *API) fetch(ctx context.Context) (data models.SiteData, err error) {
+	path, isFile, pathErr := filePath(api.BaseURL)
+	name := "HTTP " + api.Source.method()
+	if isFile {
+		name = "read file"
+	}
+	ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.HTTPMethodKey.String(api.Source.method()),
+		semconv.HTTPURLKey.String(spanURL(api.BaseURL, api.Source.Auth)),
+	))
+	defer func() {
+		countAttempt(api.Source.Name, err)
+		endSpan(span, err)
+	}()
+	if pathErr != nil {
+		log.Println("Error while reading file: ", api.BaseURL, pathErr)
+		return models.SiteData{}, pathErr
+	}
+	if isFile {
+		return readPath(path, api.Source)
+	}
+	req, err := api.newRequest(ctx)
+	if err != nil {
+		log.Println("Error while building http request: ", api.BaseURL, err)
+		return models.SiteData{}, err
+	}
+	if err := api.authenticate(req); err != nil {
+		log.Println("Error while authenticating http request: ", api.BaseURL, err)
+		return models.SiteData{}, err
+	}
+	if err := waitRate(ctx, api.Source, req.URL.Host); err != nil {
+		log.Println("Error while waiting for rate limit: ", api.BaseURL, err)
+		return models.SiteData{}, err
+	}
+	resp, err := api.Client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			// the request url may hold an API key
+			urlErr.URL = api.BaseURL
+		}
+		log.Println("Error while making http request: ", api.BaseURL, err)
+		return models.SiteData{}, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && api.Source.Auth.Type == "oauth2" {
+		// the token was revoked, get a new one on retry
+		tokens.invalidate(api.Source.Auth)
+	}
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
+	body := &countingReadCloser{ReadCloser: resp.Body}
+	resp.Body = body
+	data, err = parseResponse(api.BaseURL, resp, api.Source)
+	span.SetAttributes(semconv.HTTPResponseContentLengthKey.Int64(body.n))
+	return data, err
+}
+
+// parseResponse reads the items of the response with the decoder of source
+func parseResponse(url string, resp *http.Response, source Source) (models.SiteData, error) {
 	defer resp.Body.Close()
 	var data models.SiteData
 
 	if resp.StatusCode != http.StatusOK {
 		log.Println("Status code is not 200 for: " + url)
-		return data, errors.New("Status code is not 200 for: " + url)
+		return data, &statusError{code: resp.StatusCode, url: url}
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
+	maxBodySize := source.maxBodySize()
+	if resp.ContentLength > maxBodySize {
+		log.Println("Payload too large for: ", url, resp.ContentLength)
+		return data, bodyTooLarge(maxBodySize)
+	}
+
+	body, err := decompress(resp, url)
 	if err != nil {
-		log.Println("Error while reading http response: ", url, err)
+		log.Println("Error while decompressing http response: ", url, err)
 		return data, err
 	}
-	err = json.Unmarshal(body, &data)
+	defer body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); genericContentTypes[mediaType] || contentType == "" {
+		// static files are often served without the content type of their data
+		contentType = contentTypeByPath(urlPath(url))
+	}
+	decoder, err := decoderFor(source, contentType)
+	if err != nil {
+		log.Println("Error while selecting decoder: ", url, err)
+		return data, &decodeError{err}
+	}
+	// the size limit applies to the decompressed body
+	data.UrlData, err = decoder.Decode(&limitedReader{r: body, max: maxBodySize}, source)
+	if err == nil && len(data.UrlData) > source.maxItems() {
+		err = tooManyItems(source.maxItems())
+	}
 	if err != nil {
+		data.UrlData = nil
 		log.Println("Error while parsing http response: ", url, err)
-		return data, err
+		return data, &decodeError{err}
 	}
 	return data, nil
 }
+
+// statusError is returned for the responses whose status is not 200 OK
+type statusError struct {
+	code int
+	url  string
+}
+
+func (e *statusError) Error() string {
+	return "Status code is not 200 for: " + e.url
+}
+
+// decodeError is returned when the body of a response cannot be parsed
+type decodeError struct {
+	err error
+}
+
+func (e *decodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *decodeError) Unwrap() error {
+	return e.err
+}
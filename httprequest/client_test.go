@@ -1,8 +1,13 @@
 package httprequest
 
 import (
+	"assignment/anomaly"
+	"assignment/models"
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -43,7 +48,7 @@ func TestExecuteAPI(t *testing.T) {
 	}))
 	defer server.Close()
 
-	api := API{server.Client(), server.URL}
+	api := API{Client: server.Client(), BaseURL: server.URL}
 	data := api.ExecuteAPI()
 
 	assert.Equal(t, len(data.UrlData), 5)
@@ -58,7 +63,7 @@ func TestExecuteAPIRetry(t *testing.T) {
 	}))
 	defer server.Close()
 
-	api := API{server.Client(), server.URL}
+	api := API{Client: server.Client(), BaseURL: server.URL}
 	data := api.ExecuteAPI()
 
 	assert.Equal(t, retryCount, retries)
@@ -74,9 +79,85 @@ func TestExecuteAPIRetryTwo(t *testing.T) {
 	}))
 	defer server.Close()
 
-	api := API{server.Client(), server.URL}
+	api := API{Client: server.Client(), BaseURL: server.URL}
 	data := api.ExecuteAPI()
 
 	assert.Equal(t, retryCount, retries)
 	assert.Equal(t, len(data.UrlData), 0)
 }
+
+func TestGetContentRecordsStatus(t *testing.T) {
+	// Start a local HTTP server
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`{"data": [
+			{"url": "www.wikipedia.com/abc1", "views": 11000, "relevanceScore": 0.1},
+			{"url": "", "views": 12000, "relevanceScore": 0.2}
+		]}`))
+	}))
+	defer server.Close()
+
+	siteData := make(chan models.SiteData, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	getContent(context.Background(), Source{Name: "test", URL: server.URL}, siteData, &wg)
+	wg.Wait()
+
+	data := <-siteData
+	assert.Nil(t, data.URLError)
+	assert.Equal(t, len(data.UrlData), 1)
+
+	var status SourceStatus
+	for _, s := range Statuses() {
+		if s.Name == "test" {
+			status = s
+		}
+	}
+	assert.Equal(t, server.URL, status.URL)
+	assert.Equal(t, 1, status.Items)
+	assert.NotNil(t, status.LastSuccess)
+	assert.Equal(t, "", status.LastError)
+	assert.Equal(t, map[string]int{EmptyUrl: 1}, status.Validation.Rejected)
+	assert.False(t, status.Anomaly.Anomalous)
+}
+
+func TestGetContentQuarantine(t *testing.T) {
+	views := 1000
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`{"data": [{"url": "www.example.com/abc1", "views": ` + strconv.Itoa(views) + `, "relevanceScore": 0.1}]}`))
+	}))
+	defer server.Close()
+
+	thresholds := anomaly.DefaultThresholds
+	thresholds.MinHistory = 1
+	thresholds.Quarantine = true
+	source := Source{Name: "quarantine-test", URL: server.URL, Anomaly: &thresholds}
+	t.Cleanup(func() {
+		statuses.Lock()
+		delete(statuses.m, source.Name)
+		statuses.Unlock()
+		detectors.Lock()
+		delete(detectors.m, source.Name)
+		detectors.Unlock()
+	})
+	fetch := func() models.SiteData {
+		siteData := make(chan models.SiteData, 1)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		getContent(context.Background(), source, siteData, &wg)
+		return <-siteData
+	}
+
+	assert.Equal(t, 1000, fetch().UrlData[0].Views)
+	// the spike is quarantined with the thresholds of the source
+	views = 50000
+	assert.Equal(t, 1000, fetch().UrlData[0].Views)
+	for _, status := range Statuses() {
+		if status.Name == source.Name {
+			assert.True(t, status.Anomaly.Quarantined)
+		}
+	}
+
+	// other thresholds start over without history
+	thresholds.Quarantine = false
+	assert.Equal(t, 50000, fetch().UrlData[0].Views)
+}
@@ -0,0 +1,7 @@
+package httprequest
+
+import "time"
+
+// timeNow returns the current time. It is replaced in tests to get stable
+// results from the code that depends on it.
+var timeNow = time.Now
@@ -0,0 +1,76 @@
+package httprequest
+
+import (
+	"assignment/metrics"
+	"assignment/models"
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// call is a fetch of a source shared by every caller asking for it while it runs
+type call struct {
+	done    chan struct{}
+	data    models.SiteData
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// inflight holds the running fetches by source name
+var inflight = struct {
+	sync.Mutex
+	m map[string]*call
+}{m: make(map[string]*call)}
+
+// coalesce runs fetch for key unless a fetch of key is already running, and
+// returns its data. The fetch is not tied to the context of the caller that
+// started it: it goes on while any caller waits for it, and is canceled when
+// the contexts of all of them are done.
+func coalesce(ctx context.Context, key string, fetch func(ctx context.Context) models.SiteData) models.SiteData {
+	inflight.Lock()
+	c, ok := inflight.m[key]
+	metrics.CacheLookup("coalesce", ok)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("coalesced", ok))
+	if !ok {
+		// the spans of the fetch belong to the trace of the caller that started it
+		fetchCtx, cancel := context.WithCancel(trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx)))
+		c = &call{done: make(chan struct{}), cancel: cancel}
+		inflight.m[key] = c
+		metrics.FetchesInFlight.Inc()
+		go func() {
+			defer cancel()
+			defer metrics.FetchesInFlight.Dec()
+			c.data = fetch(fetchCtx)
+			inflight.Lock()
+			if inflight.m[key] == c {
+				delete(inflight.m, key)
+			}
+			inflight.Unlock()
+			close(c.done)
+		}()
+	}
+	c.waiters++
+	inflight.Unlock()
+
+	select {
+	case <-c.done:
+		data := c.data
+		// every caller gets its own slice
+		data.UrlData = append([]models.UrlData(nil), c.data.UrlData...)
+		return data
+	case <-ctx.Done():
+		inflight.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			// nobody waits anymore, later callers start a new fetch
+			c.cancel()
+			if inflight.m[key] == c {
+				delete(inflight.m, key)
+			}
+		}
+		inflight.Unlock()
+		return models.SiteData{URLError: ctx.Err()}
+	}
+}
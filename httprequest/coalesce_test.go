@@ -0,0 +1,133 @@
+package httprequest
+
+import (
+	"assignment/models"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// waitForWaiters blocks until n callers wait for the fetch of key
+func waitForWaiters(t *testing.T, key string, n int) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		inflight.Lock()
+		c, ok := inflight.m[key]
+		waiting := ok && c.waiters == n
+		inflight.Unlock()
+		if waiting {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%d callers are not waiting for %s", n, key)
+}
+
+func TestGetContentCoalesced(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		rw.Write([]byte(authBody))
+	}))
+	defer server.Close()
+
+	source := Source{Name: "coalesced", URL: server.URL}
+	siteData := make(chan models.SiteData, 5)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go getContent(context.Background(), source, siteData, &wg)
+	}
+	waitForWaiters(t, source.Name, 5)
+	close(release)
+	wg.Wait()
+	close(siteData)
+
+	for data := range siteData {
+		assert.Nil(t, data.URLError)
+		assert.Len(t, data.UrlData, 1)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestCoalesceLeaderCanceled(t *testing.T) {
+	release := make(chan struct{})
+	var fetchErr error
+	fetch := func(ctx context.Context) models.SiteData {
+		select {
+		case <-release:
+		case <-ctx.Done():
+			fetchErr = ctx.Err()
+		}
+		return models.SiteData{UrlData: []models.UrlData{{Url: "www.example.com/abc1"}}}
+	}
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leader := make(chan models.SiteData, 1)
+	go func() { leader <- coalesce(leaderCtx, "leader", fetch) }()
+	waitForWaiters(t, "leader", 1)
+	follower := make(chan models.SiteData, 1)
+	go func() { follower <- coalesce(context.Background(), "leader", fetch) }()
+	waitForWaiters(t, "leader", 2)
+
+	// the leader gives up, the fetch goes on for the follower
+	cancelLeader()
+	assert.Equal(t, context.Canceled, (<-leader).URLError)
+	close(release)
+	data := <-follower
+	assert.Nil(t, data.URLError)
+	assert.Len(t, data.UrlData, 1)
+	assert.Nil(t, fetchErr)
+}
+
+func TestCoalesceAllCanceled(t *testing.T) {
+	var calls int32
+	canceled := make(chan struct{})
+	fetch := func(ctx context.Context) models.SiteData {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-ctx.Done()
+			close(canceled)
+		}
+		return models.SiteData{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan models.SiteData, 1)
+	go func() { done <- coalesce(ctx, "canceled", fetch) }()
+	waitForWaiters(t, "canceled", 1)
+	cancel()
+	assert.Equal(t, context.Canceled, (<-done).URLError)
+
+	// the abandoned fetch is canceled and the next caller starts a new one
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("fetch was not canceled")
+	}
+	data := coalesce(context.Background(), "canceled", fetch)
+	assert.Nil(t, data.URLError)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestExecuteAPIContextCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	api := API{Client: server.Client(), BaseURL: server.URL}
+	start := time.Now()
+	data := api.ExecuteAPIContext(ctx)
+	assert.Equal(t, context.DeadlineExceeded, data.URLError)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
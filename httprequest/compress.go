@@ -0,0 +1,141 @@
+package httprequest
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// acceptEncoding lists the encodings negotiated with upstream servers
+const acceptEncoding = "gzip, br, zstd"
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// genericContentTypes say nothing about the data, the decoder is then selected by the url extension
+var genericContentTypes = map[string]bool{
+	"application/octet-stream": true,
+	"application/gzip":         true,
+	"application/x-gzip":       true,
+	"application/zstd":         true,
+	"text/plain":               true,
+}
+
+// compressedExtensions are the file extensions of compressed files and their encoding
+var compressedExtensions = map[string]string{
+	".gz":  "gzip",
+	".br":  "br",
+	".zst": "zstd",
+}
+
+// decompressed reads the decoded body and closes the decoders along with the body
+type decompressed struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (d *decompressed) Close() error {
+	var err error
+	for i := len(d.closers) - 1; i >= 0; i-- {
+		if closeErr := d.closers[i].Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// decompress returns the body of resp decoded according to its Content-Encoding.
+// Bodies without Content-Encoding, like compressed static files, are detected by
+// their gzip or zstd magic bytes, or by the .br extension of rawURL for brotli.
+func decompress(resp *http.Response, rawURL string) (io.ReadCloser, error) {
+	body := &decompressed{Reader: resp.Body, closers: []io.Closer{resp.Body}}
+
+	var encodings []string
+	header := strings.TrimSpace(resp.Header.Get("Content-Encoding"))
+	if header == "" || strings.EqualFold(header, "identity") {
+		buffered := bufio.NewReader(resp.Body)
+		body.Reader = buffered
+		if encoding := sniffEncoding(buffered, rawURL); encoding != "" {
+			encodings = []string{encoding}
+		}
+	} else {
+		encodings = strings.Split(header, ",")
+	}
+
+	// encodings are listed in the order they were applied
+	for i := len(encodings) - 1; i >= 0; i-- {
+		switch encoding := strings.ToLower(strings.TrimSpace(encodings[i])); encoding {
+		case "gzip", "x-gzip":
+			reader, err := gzip.NewReader(body.Reader)
+			if err != nil {
+				body.Close()
+				return nil, err
+			}
+			body.Reader = reader
+			body.closers = append(body.closers, reader)
+		case "br":
+			body.Reader = brotli.NewReader(body.Reader)
+		case "zstd":
+			reader, err := zstd.NewReader(body.Reader)
+			if err != nil {
+				body.Close()
+				return nil, err
+			}
+			readCloser := reader.IOReadCloser()
+			body.Reader = readCloser
+			body.closers = append(body.closers, readCloser)
+		case "identity", "":
+		default:
+			body.Close()
+			return nil, errors.New("unsupported Content-Encoding: " + encoding)
+		}
+	}
+	return body, nil
+}
+
+// sniffEncoding detects the compression of a body without Content-Encoding
+func sniffEncoding(body *bufio.Reader, rawURL string) string {
+	magic, _ := body.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return "gzip"
+	case bytes.HasPrefix(magic, zstdMagic):
+		return "zstd"
+	case compressedExtensions[path.Ext(urlPath(rawURL))] == "br":
+		// brotli streams have no magic bytes
+		return "br"
+	}
+	return ""
+}
+
+// contentTypeByPath returns the content type of the decoder for a file path,
+// ignoring the extension of a compressed file
+func contentTypeByPath(filePath string) string {
+	ext := strings.ToLower(path.Ext(filePath))
+	if _, ok := compressedExtensions[ext]; ok {
+		ext = strings.ToLower(path.Ext(strings.TrimSuffix(filePath, path.Ext(filePath))))
+	}
+	return fileContentTypes[ext]
+}
+
+func urlPath(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	if u.Opaque != "" {
+		return u.Opaque
+	}
+	return u.Path
+}
@@ -0,0 +1,126 @@
+package httprequest
+
+import (
+	"assignment/models"
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+const compressBody = `{"data": [{"url": "www.example.com/abc1", "views": 1000, "relevanceScore": 0.1}]}`
+
+var compressData = []models.UrlData{
+	{Url: "www.example.com/abc1", Views: 1000, RelevanceScore: 0.1},
+}
+
+func compress(t *testing.T, encoding, body string) []byte {
+	var buf bytes.Buffer
+	switch encoding {
+	case "gzip":
+		w := gzip.NewWriter(&buf)
+		w.Write([]byte(body))
+		w.Close()
+	case "br":
+		w := brotli.NewWriter(&buf)
+		w.Write([]byte(body))
+		w.Close()
+	case "zstd":
+		w, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(body))
+		w.Close()
+	default:
+		buf.WriteString(body)
+	}
+	return buf.Bytes()
+}
+
+func TestExecuteAPIContentEncoding(t *testing.T) {
+	for _, encoding := range []string{"gzip", "br", "zstd"} {
+		t.Run(encoding, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				assert.Equal(t, acceptEncoding, req.Header.Get("Accept-Encoding"))
+				rw.Header().Set("Content-Encoding", encoding)
+				rw.Write(compress(t, encoding, compressBody))
+			}))
+			defer server.Close()
+
+			api := API{Client: server.Client(), BaseURL: server.URL}
+			data := api.ExecuteAPI()
+			assert.Nil(t, data.URLError)
+			assert.Equal(t, compressData, data.UrlData)
+		})
+	}
+}
+
+func TestExecuteAPIStaticCompressedFile(t *testing.T) {
+	tests := []struct {
+		path     string
+		encoding string
+		body     string
+	}{
+		{"/google.json.gz", "gzip", compressBody},
+		{"/google.csv.zst", "zstd", "url,views,relevanceScore\nwww.example.com/abc1,1000,0.1\n"},
+		{"/google.json.br", "br", compressBody},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set("Content-Type", "application/octet-stream")
+				rw.Write(compress(t, tt.encoding, tt.body))
+			}))
+			defer server.Close()
+
+			api := API{Client: server.Client(), BaseURL: server.URL + tt.path}
+			data := api.ExecuteAPI()
+			assert.Nil(t, data.URLError)
+			assert.Equal(t, compressData, data.UrlData)
+		})
+	}
+}
+
+func TestExecuteAPICompressedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.json.gz"), string(compress(t, "gzip", compressBody)))
+	writeFile(t, filepath.Join(dir, "b.csv.zst"), string(compress(t, "zstd", "url,views\nwww.example.com/abc2,2000\n")))
+	writeFile(t, filepath.Join(dir, "c.json.br"), string(compress(t, "br", `{"data": [{"url": "www.example.com/abc3"}]}`)))
+
+	api := API{BaseURL: "file://" + filepath.ToSlash(dir), Source: Source{Pattern: "*.*"}}
+	data := api.ExecuteAPI()
+	assert.Nil(t, data.URLError)
+	assert.Equal(t, []models.UrlData{
+		{Url: "www.example.com/abc1", Views: 1000, RelevanceScore: 0.1},
+		{Url: "www.example.com/abc2", Views: 2000},
+		{Url: "www.example.com/abc3"},
+	}, data.UrlData)
+}
+
+func TestDecompressionBombLimited(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Encoding", "gzip")
+		rw.Write(compress(t, "gzip", `{"data": [`+string(bytes.Repeat([]byte(" "), 1<<20))+`]}`))
+	}))
+	defer server.Close()
+
+	api := API{Client: server.Client(), BaseURL: server.URL, Source: Source{MaxBodySize: 1 << 16}}
+	data := api.ExecuteAPI()
+	assert.ErrorIs(t, data.URLError, ErrPayloadTooLarge)
+}
+
+func Test_decompressUnsupported(t *testing.T) {
+	resp := &http.Response{
+		Header: http.Header{"Content-Encoding": {"compress"}},
+		Body:   http.NoBody,
+	}
+	_, err := decompress(resp, "http://example.com")
+	assert.EqualError(t, err, "unsupported Content-Encoding: compress")
+}
@@ -0,0 +1,316 @@
+package httprequest
+
+import (
+	"assignment/models"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Decoder reads the items of a source from a response body
+type Decoder interface {
+	Decode(r io.Reader, source Source) ([]models.UrlData, error)
+}
+
+// DecoderFunc adapts a function to a Decoder
+type DecoderFunc func(r io.Reader, source Source) ([]models.UrlData, error)
+
+// Decode calls f(r, source)
+func (f DecoderFunc) Decode(r io.Reader, source Source) ([]models.UrlData, error) {
+	return f(r, source)
+}
+
+// CSVOptions configures how CSV sources are read.
+// The first record is the header naming the columns.
+type CSVOptions struct {
+	Delimiter      string `json:"delimiter"`      // default ","
+	Url            string `json:"url"`            // column of the url, default "url"
+	Views          string `json:"views"`          // column of the views, default "views"
+	RelevanceScore string `json:"relevanceScore"` // column of the relevanceScore, default "relevanceScore"
+}
+
+var decoders = struct {
+	sync.RWMutex
+	formats      map[string]Decoder
+	contentTypes map[string]string
+}{
+	formats: map[string]Decoder{
+		"json":   DecoderFunc(decodeJSON),
+		"ndjson": DecoderFunc(decodeNDJSON),
+		"csv":    DecoderFunc(decodeCSV),
+		"feed":   DecoderFunc(decodeFeed),
+	},
+	contentTypes: map[string]string{
+		"application/json":     "json",
+		"application/x-ndjson": "ndjson",
+		"application/ndjson":   "ndjson",
+		"application/jsonl":    "ndjson",
+		"text/csv":             "csv",
+		"application/rss+xml":  "feed",
+		"application/atom+xml": "feed",
+		"application/rdf+xml":  "feed",
+		// feeds are often served as generic XML, rejected by the feed
+		// decoder unless their root element is rss, RDF or feed
+		"application/xml": "feed",
+		"text/xml":        "feed",
+	},
+}
+
+// RegisterDecoder makes decoder available as format for sources,
+// and selects it for responses with any of contentTypes
+func RegisterDecoder(format string, decoder Decoder, contentTypes ...string) {
+	decoders.Lock()
+	defer decoders.Unlock()
+	decoders.formats[format] = decoder
+	for _, contentType := range contentTypes {
+		decoders.contentTypes[contentType] = format
+	}
+}
+
+// decoderFor returns the decoder of the source format, or else the one
+// registered for contentType, defaulting to JSON
+func decoderFor(source Source, contentType string) (Decoder, error) {
+	format := formatFor(source, contentType)
+	decoders.RLock()
+	defer decoders.RUnlock()
+	decoder, ok := decoders.formats[format]
+	if !ok {
+		return nil, errors.New("unknown source format: " + format)
+	}
+	return decoder, nil
+}
+
+// formatFor returns the source format, or else the one registered for
+// contentType, defaulting to JSON
+func formatFor(source Source, contentType string) string {
+	if source.Format != "" {
+		return source.Format
+	}
+	decoders.RLock()
+	defer decoders.RUnlock()
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if format := decoders.contentTypes[mediaType]; format != "" {
+		return format
+	}
+	return "json"
+}
+
+// decodeJSON streams the items array located by the source mapping, decoding
+// one item at a time so only the items are held in memory
+func decodeJSON(r io.Reader, source Source) ([]models.UrlData, error) {
+	mapping, err := source.Mapping.compile()
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	found, err := seek(decoder, mapping.items)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	token, err := decoder.Token()
+	if err != nil || token == nil {
+		return nil, err
+	}
+	if token != json.Delim('[') {
+		return nil, errors.New("items are not an array")
+	}
+	maxItems := source.maxItems()
+	var data []models.UrlData
+	for i := 0; decoder.More(); i++ {
+		if i == maxItems {
+			return nil, tooManyItems(maxItems)
+		}
+		var item interface{}
+		if err := decoder.Decode(&item); err != nil {
+			return nil, err
+		}
+		urlData, err := mapping.item(item)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %v", i, err)
+		}
+		data = append(data, urlData)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// seek advances decoder to the start of the value at path, reporting whether it exists
+func seek(decoder *json.Decoder, path []string) (bool, error) {
+	for _, segment := range path {
+		token, err := decoder.Token()
+		if err != nil {
+			return false, err
+		}
+		found := false
+		switch token {
+		case json.Delim('{'):
+			for !found && decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					return false, err
+				}
+				if key == segment {
+					found = true
+				} else if err := skip(decoder); err != nil {
+					return false, err
+				}
+			}
+		case json.Delim('['):
+			index, err := strconv.Atoi(segment)
+			if err != nil {
+				return false, nil
+			}
+			for i := 0; !found && decoder.More(); i++ {
+				if i == index {
+					found = true
+				} else if err := skip(decoder); err != nil {
+					return false, err
+				}
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// skip consumes the next value of decoder without keeping it
+func skip(decoder *json.Decoder) error {
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// decodeNDJSON reads one JSON item per line, its fields located with the source mapping
+func decodeNDJSON(r io.Reader, source Source) ([]models.UrlData, error) {
+	mapping, err := source.Mapping.compile()
+	if err != nil {
+		return nil, err
+	}
+	maxItems := source.maxItems()
+	var data []models.UrlData
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if len(data) == maxItems {
+			return nil, tooManyItems(maxItems)
+		}
+		var item interface{}
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.UseNumber()
+		if err := decoder.Decode(&item); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		urlData, err := mapping.item(item)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		data = append(data, urlData)
+	}
+	return data, scanner.Err()
+}
+
+// decodeCSV reads a CSV document with a header, its columns named by the source CSV options
+func decodeCSV(r io.Reader, source Source) ([]models.UrlData, error) {
+	options := source.CSV.withDefaults()
+	reader := csv.NewReader(r)
+	reader.Comma, _ = utf8.DecodeRuneInString(options.Delimiter)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	urlColumn, ok := columns[options.Url]
+	if !ok {
+		return nil, errors.New("csv column missing: " + options.Url)
+	}
+
+	maxItems := source.maxItems()
+	var data []models.UrlData
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == maxItems {
+			return nil, tooManyItems(maxItems)
+		}
+		line, _ := reader.FieldPos(0)
+		urlData := models.UrlData{Url: record[urlColumn]}
+		if i, ok := columns[options.Views]; ok && record[i] != "" {
+			if urlData.Views, err = toInt(record[i]); err != nil {
+				return nil, fmt.Errorf("line %d: views: %v", line, err)
+			}
+		}
+		if i, ok := columns[options.RelevanceScore]; ok && record[i] != "" {
+			if urlData.RelevanceScore, err = toFloat(record[i]); err != nil {
+				return nil, fmt.Errorf("line %d: relevanceScore: %v", line, err)
+			}
+		}
+		data = append(data, urlData)
+	}
+}
+
+func (o CSVOptions) withDefaults() CSVOptions {
+	if o.Delimiter == "" {
+		o.Delimiter = ","
+	}
+	if o.Url == "" {
+		o.Url = "url"
+	}
+	if o.Views == "" {
+		o.Views = "views"
+	}
+	if o.RelevanceScore == "" {
+		o.RelevanceScore = "relevanceScore"
+	}
+	return o
+}
+
+func (o CSVOptions) check() error {
+	if o.Delimiter != "" && utf8.RuneCountInString(o.Delimiter) != 1 {
+		return errors.New("csv 'delimiter' must be a single character")
+	}
+	return nil
+}
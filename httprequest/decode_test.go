@@ -0,0 +1,107 @@
+package httprequest
+
+import (
+	"assignment/models"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_decodeCSV(t *testing.T) {
+	body := "link;hits;score;extra\n" +
+		"www.example.com/abc1;1000;0.1;x\n" +
+		"\"www.example.com/abc;2\"; 2000 ;0.2;y\n" +
+		"www.example.com/abc3;;;z\n"
+	source := Source{CSV: CSVOptions{Delimiter: ";", Url: "link", Views: "hits", RelevanceScore: "score"}}
+
+	data, err := decodeCSV(strings.NewReader(body), source)
+	assert.Nil(t, err)
+	assert.Equal(t, []models.UrlData{
+		{Url: "www.example.com/abc1", Views: 1000, RelevanceScore: 0.1},
+		{Url: "www.example.com/abc;2", Views: 2000, RelevanceScore: 0.2},
+		{Url: "www.example.com/abc3"},
+	}, data)
+}
+
+func Test_decodeCSVInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"TestUrlColumnMissing", "link,views\nwww.example.com/abc1,1000\n"},
+		{"TestViewsNotNumber", "url,views\nwww.example.com/abc1,many\n"},
+		{"TestWrongFieldCount", "url,views\nwww.example.com/abc1,1000,0.5\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeCSV(strings.NewReader(tt.body), Source{})
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func Test_decodeNDJSON(t *testing.T) {
+	body := `{"url": "www.example.com/abc1", "views": 1000, "relevanceScore": 0.1}
+
+{"url": "www.example.com/abc2", "views": "2000", "relevanceScore": 0.2}
+`
+	data, err := decodeNDJSON(strings.NewReader(body), Source{})
+	assert.Nil(t, err)
+	assert.Equal(t, []models.UrlData{
+		{Url: "www.example.com/abc1", Views: 1000, RelevanceScore: 0.1},
+		{Url: "www.example.com/abc2", Views: 2000, RelevanceScore: 0.2},
+	}, data)
+
+	_, err = decodeNDJSON(strings.NewReader("{\"url\": \"a\"}\n{invalid}\n"), Source{})
+	assert.EqualError(t, err, "line 2: invalid character 'i' looking for beginning of object key string")
+}
+
+func TestExecuteAPIContentType(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		contentType string
+		body        string
+	}{
+		{"TestCSVContentType", "", "text/csv; charset=utf-8", "url,views,relevanceScore\nwww.example.com/abc1,1000,0.1\n"},
+		{"TestNDJSONContentType", "", "application/x-ndjson", `{"url": "www.example.com/abc1", "views": 1000, "relevanceScore": 0.1}`},
+		{"TestJSONContentType", "", "application/json", `{"data": [{"url": "www.example.com/abc1", "views": 1000, "relevanceScore": 0.1}]}`},
+		{"TestDefaultJSON", "", "text/plain", `{"data": [{"url": "www.example.com/abc1", "views": 1000, "relevanceScore": 0.1}]}`},
+		{"TestFormatOverridesContentType", "csv", "text/plain", "url,views,relevanceScore\nwww.example.com/abc1,1000,0.1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set("Content-Type", tt.contentType)
+				rw.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			api := API{Client: server.Client(), BaseURL: server.URL, Source: Source{Format: tt.format}}
+			data := api.ExecuteAPI()
+			assert.Nil(t, data.URLError)
+			assert.Equal(t, []models.UrlData{
+				{Url: "www.example.com/abc1", Views: 1000, RelevanceScore: 0.1},
+			}, data.UrlData)
+		})
+	}
+}
+
+func TestRegisterDecoder(t *testing.T) {
+	RegisterDecoder("lines", DecoderFunc(func(r io.Reader, source Source) ([]models.UrlData, error) {
+		return []models.UrlData{{Url: "www.example.com/abc1"}}, nil
+	}), "text/x-lines")
+
+	decoder, err := decoderFor(Source{}, "text/x-lines")
+	assert.Nil(t, err)
+	data, err := decoder.Decode(strings.NewReader(""), Source{})
+	assert.Nil(t, err)
+	assert.Equal(t, "www.example.com/abc1", data[0].Url)
+
+	_, err = decoderFor(Source{Format: "xml"}, "")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,180 @@
+package httprequest
+
+import (
+	"assignment/models"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+const defaultHalfLife = 24 * time.Hour
+
+// FeedOptions configures how RSS and Atom feed entries become url data
+type FeedOptions struct {
+	// ViewsElement is the local name of an entry element holding the views,
+	// usually an extension element such as <stats:views>
+	ViewsElement string `json:"viewsElement"`
+	// DefaultViews is used for entries without a views element
+	DefaultViews int `json:"defaultViews"`
+	// RelevanceElement is the local name of an entry element holding the
+	// relevanceScore. When empty, the relevanceScore is derived from recency.
+	RelevanceElement string `json:"relevanceElement"`
+	// HalfLife is the entry age at which the recency relevanceScore is 0.5, default 24h
+	HalfLife Duration `json:"halfLife"`
+}
+
+// xmlNode is any XML element with its attributes, text and children
+type xmlNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Content  string     `xml:",chardata"`
+	Children []xmlNode  `xml:",any"`
+}
+
+var feedDateLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02",
+}
+
+// decodeFeed reads the entries of an RSS 2.0, RSS 1.0 or Atom feed.
+// The link of an entry is its url.
+func decodeFeed(r io.Reader, source Source) ([]models.UrlData, error) {
+	var root xmlNode
+	decoder := xml.NewDecoder(r)
+	// feeds declaring another encoding than utf-8, such as iso-8859-1, are converted
+	decoder.CharsetReader = charset.NewReaderLabel
+	if err := decoder.Decode(&root); err != nil {
+		return nil, err
+	}
+
+	var entries []xmlNode
+	switch root.XMLName.Local {
+	case "rss":
+		channel, ok := root.child("channel")
+		if !ok {
+			return nil, errors.New("rss feed without channel")
+		}
+		entries = channel.children("item")
+	case "RDF":
+		entries = root.children("item")
+	case "feed":
+		entries = root.children("entry")
+	default:
+		return nil, errors.New("not an RSS or Atom feed: " + root.XMLName.Local)
+	}
+
+	options := source.Feed
+	halfLife := time.Duration(options.HalfLife)
+	if halfLife <= 0 {
+		halfLife = defaultHalfLife
+	}
+	data := make([]models.UrlData, 0, len(entries))
+	for _, entry := range entries {
+		urlData := models.UrlData{Url: entry.link(), Views: options.DefaultViews}
+		if urlData.Url == "" {
+			urlData.Url = entry.text("guid")
+		}
+		var err error
+		if options.ViewsElement != "" {
+			if views, ok := entry.child(options.ViewsElement); ok {
+				if urlData.Views, err = toInt(views.Content); err != nil {
+					return nil, errors.New("entry " + urlData.Url + ": views: " + err.Error())
+				}
+			}
+		}
+		if options.RelevanceElement != "" {
+			if relevance, ok := entry.child(options.RelevanceElement); ok {
+				if urlData.RelevanceScore, err = toFloat(relevance.Content); err != nil {
+					return nil, errors.New("entry " + urlData.Url + ": relevanceScore: " + err.Error())
+				}
+			}
+		} else if published, ok := entry.date(); ok {
+			urlData.RelevanceScore = recency(timeNow().Sub(published), halfLife)
+		}
+		data = append(data, urlData)
+	}
+	return data, nil
+}
+
+// recency decays from 1 for new entries to 0.5 at halfLife and towards 0 afterwards
+func recency(age, halfLife time.Duration) float64 {
+	if age < 0 {
+		age = 0
+	}
+	return math.Pow(0.5, float64(age)/float64(halfLife))
+}
+
+func (n xmlNode) child(local string) (xmlNode, bool) {
+	for _, child := range n.Children {
+		if child.XMLName.Local == local {
+			return child, true
+		}
+	}
+	return xmlNode{}, false
+}
+
+func (n xmlNode) children(local string) []xmlNode {
+	var children []xmlNode
+	for _, child := range n.Children {
+		if child.XMLName.Local == local {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+func (n xmlNode) text(local string) string {
+	child, _ := n.child(local)
+	return strings.TrimSpace(child.Content)
+}
+
+func (n xmlNode) attr(local string) string {
+	for _, attr := range n.Attrs {
+		if attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// link returns the RSS <link> text or the Atom alternate <link href>
+func (n xmlNode) link() string {
+	for _, link := range n.children("link") {
+		if href := link.attr("href"); href != "" {
+			if rel := link.attr("rel"); rel == "" || rel == "alternate" {
+				return href
+			}
+			continue
+		}
+		if text := strings.TrimSpace(link.Content); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// date returns the publication or update time of an entry
+func (n xmlNode) date() (time.Time, bool) {
+	for _, local := range []string{"pubDate", "published", "updated", "date"} {
+		value := n.text(local)
+		if value == "" {
+			continue
+		}
+		for _, layout := range feedDateLayouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,172 @@
+package httprequest
+
+import (
+	"assignment/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const rssFeed = `<?xml version="1.0"?>
+<rss version="2.0" xmlns:stats="http://example.com/stats">
+  <channel>
+    <title>Example</title>
+    <item>
+      <title>New</title>
+      <link>https://www.example.com/new</link>
+      <pubDate>Sun, 01 May 2022 12:00:00 +0000</pubDate>
+      <stats:views>1500</stats:views>
+    </item>
+    <item>
+      <title>Old</title>
+      <link> https://www.example.com/old </link>
+      <pubDate>Sat, 30 Apr 2022 12:00:00 GMT</pubDate>
+    </item>
+    <item>
+      <guid>https://www.example.com/guid</guid>
+    </item>
+  </channel>
+</rss>`
+
+const atomFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:stats="http://example.com/stats">
+  <title>Example</title>
+  <entry>
+    <title>First</title>
+    <link rel="self" href="https://www.example.com/first.atom"/>
+    <link href="https://www.example.com/first"/>
+    <updated>2022-05-01T00:00:00Z</updated>
+    <stats:views>100</stats:views>
+    <stats:score>0.8</stats:score>
+  </entry>
+  <entry>
+    <title>Second</title>
+    <link rel="alternate" href="https://www.example.com/second"/>
+    <published>2022-04-30T00:00:00Z</published>
+  </entry>
+</feed>`
+
+func withTimeNow(t *testing.T, fixed time.Time) {
+	timeNow = func() time.Time { return fixed }
+	t.Cleanup(func() { timeNow = time.Now })
+}
+
+func Test_decodeFeedRSS(t *testing.T) {
+	withTimeNow(t, time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC))
+	source := Source{Feed: FeedOptions{ViewsElement: "views", DefaultViews: 10}}
+
+	data, err := decodeFeed(strings.NewReader(rssFeed), source)
+	assert.Nil(t, err)
+	assert.Equal(t, []models.UrlData{
+		{Url: "https://www.example.com/new", Views: 1500, RelevanceScore: 1},
+		{Url: "https://www.example.com/old", Views: 10, RelevanceScore: 0.5},
+		{Url: "https://www.example.com/guid", Views: 10, RelevanceScore: 0},
+	}, data)
+}
+
+func Test_decodeFeedAtom(t *testing.T) {
+	withTimeNow(t, time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC))
+
+	source := Source{Feed: FeedOptions{HalfLife: Duration(12 * time.Hour)}}
+	data, err := decodeFeed(strings.NewReader(atomFeed), source)
+	assert.Nil(t, err)
+	assert.Equal(t, []models.UrlData{
+		{Url: "https://www.example.com/first", Views: 0, RelevanceScore: 1},
+		{Url: "https://www.example.com/second", Views: 0, RelevanceScore: 0.25},
+	}, data)
+
+	source = Source{Feed: FeedOptions{ViewsElement: "views", RelevanceElement: "score"}}
+	data, err = decodeFeed(strings.NewReader(atomFeed), source)
+	assert.Nil(t, err)
+	assert.Equal(t, []models.UrlData{
+		{Url: "https://www.example.com/first", Views: 100, RelevanceScore: 0.8},
+		{Url: "https://www.example.com/second", Views: 0, RelevanceScore: 0},
+	}, data)
+}
+
+func Test_decodeFeedLatin1(t *testing.T) {
+	// "café" and "señor" in iso-8859-1
+	feed := "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n" +
+		"<rss version=\"2.0\"><channel><title>Caf\xe9</title>" +
+		"<item><link>https://www.example.com/se\xf1or</link><views>7</views></item>" +
+		"</channel></rss>"
+
+	source := Source{Feed: FeedOptions{ViewsElement: "views"}}
+	data, err := decodeFeed(strings.NewReader(feed), source)
+	assert.Nil(t, err)
+	assert.Equal(t, []models.UrlData{
+		{Url: "https://www.example.com/señor", Views: 7, RelevanceScore: 0},
+	}, data)
+}
+
+func Test_decodeFeedInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"TestNotXML", `{"data": []}`},
+		{"TestNotFeed", `<html><body></body></html>`},
+		{"TestRSSWithoutChannel", `<rss version="2.0"></rss>`},
+		{"TestViewsNotNumber", `<feed><entry><link href="a"/><views>many</views></entry></feed>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := Source{Feed: FeedOptions{ViewsElement: "views"}}
+			_, err := decodeFeed(strings.NewReader(tt.body), source)
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestExecuteAPIFeed(t *testing.T) {
+	withTimeNow(t, time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC))
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/rss+xml")
+		rw.Write([]byte(rssFeed))
+	}))
+	defer server.Close()
+
+	api := API{Client: server.Client(), BaseURL: server.URL}
+	data := api.ExecuteAPI()
+	assert.Nil(t, data.URLError)
+	assert.Equal(t, 3, len(data.UrlData))
+	assert.Equal(t, "https://www.example.com/new", data.UrlData[0].Url)
+}
+
+func TestExecuteAPIFeedGenericXML(t *testing.T) {
+	withTimeNow(t, time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC))
+	body := atomFeed
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		rw.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	api := API{Client: server.Client(), BaseURL: server.URL}
+	data := api.ExecuteAPI()
+	assert.Nil(t, data.URLError)
+	assert.NotEmpty(t, data.UrlData)
+
+	// XML that is not a feed is rejected by the root element
+	defer func(retries int) { Retries = retries }(Retries)
+	Retries = 1
+	body = `<?xml version="1.0"?><catalog><book/></catalog>`
+	data = api.ExecuteAPI()
+	assert.EqualError(t, data.URLError, "not an RSS or Atom feed: catalog")
+}
+
+func TestDurationJSON(t *testing.T) {
+	var d Duration
+	assert.Nil(t, d.UnmarshalJSON([]byte(`"1h30m"`)))
+	assert.Equal(t, Duration(90*time.Minute), d)
+	b, err := d.MarshalJSON()
+	assert.Nil(t, err)
+	assert.Equal(t, `"1h30m0s"`, string(b))
+
+	assert.NotNil(t, d.UnmarshalJSON([]byte(`90`)))
+	assert.NotNil(t, d.UnmarshalJSON([]byte(`"abc"`)))
+}
@@ -0,0 +1,183 @@
+package httprequest
+
+import (
+	"assignment/metrics"
+	"assignment/models"
+	"errors"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultPattern = "*.json"
+
+// fileContentTypes are the content types of file extensions with a decoder
+var fileContentTypes = map[string]string{
+	".json":   "application/json",
+	".ndjson": "application/x-ndjson",
+	".jsonl":  "application/x-ndjson",
+	".csv":    "text/csv",
+	".rss":    "application/rss+xml",
+	".atom":   "application/atom+xml",
+}
+
+// cachedFile is the data read from a file of a directory source
+type cachedFile struct {
+	modTime time.Time
+	size    int64
+	data    []models.UrlData
+}
+
+// fileKey is a file read by a source, whose data depends on the format,
+// mapping and validation of the source
+type fileKey struct {
+	source string
+	path   string
+}
+
+// fileCache keeps the data of directory source files so only changed files are read again
+var fileCache = struct {
+	sync.Mutex
+	files map[fileKey]cachedFile
+}{files: make(map[fileKey]cachedFile)}
+
+// filePath returns the local path of a file url. A file url naming a host
+// other than localhost is rejected, file://data/google.json being the
+// absolute path /google.json on host data.
+func filePath(rawURL string) (string, bool, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "file" {
+		return "", false, nil
+	}
+	if u.Opaque != "" {
+		return u.Opaque, true, nil // relative path as in file:data/google.json
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return "", true, errors.New("file url has a host: " + rawURL + ", write file:" + u.Host + u.Path + " for a relative path")
+	}
+	return filepath.FromSlash(u.Path), true, nil
+}
+
+// readPath reads a file, or every file matching the source pattern in a directory
+func readPath(path string, source Source) (models.SiteData, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Println("Error while reading file: ", path, err)
+		return models.SiteData{}, err
+	}
+	if info.IsDir() {
+		return readDir(path, source)
+	}
+	return readFile(path, source)
+}
+
+// readFile parses a file the same way as an http response
+func readFile(path string, source Source) (models.SiteData, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Println("Error while reading file: ", path, err)
+		return models.SiteData{}, err
+	}
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {fileContentType(path)}},
+		Body:       file,
+	}
+	return parseResponse("file://"+filepath.ToSlash(path), resp, source)
+}
+
+// fileContentType returns the content type of a file by its extension
+func fileContentType(path string) string {
+	if contentType := contentTypeByPath(filepath.ToSlash(path)); contentType != "" {
+		return contentType
+	}
+	return mime.TypeByExtension(filepath.Ext(path))
+}
+
+// cacheable reports whether the data read from the file at path can be
+// cached: the relevance scores of feed entries decay with their age, unless
+// they are read from an element of the entries
+func cacheable(path string, source Source) bool {
+	return formatFor(source, fileContentType(path)) != "feed" || source.Feed.RelevanceElement != ""
+}
+
+// readDir reads every file of dir matching the source pattern, reusing the
+// cached data of the cacheable files unchanged since the last read. The data of the
+// readable files is returned along with an error naming the others.
+func readDir(dir string, source Source) (models.SiteData, error) {
+	var data models.SiteData
+	dir = filepath.Clean(dir)
+	pattern := source.Pattern
+	if pattern == "" {
+		pattern = defaultPattern
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		log.Println("Error while reading directory: ", dir, err)
+		return data, err
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+
+	var failed []string
+	seen := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		if ok, _ := filepath.Match(pattern, info.Name()); !ok || info.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, info.Name())
+		cache := cacheable(path, source)
+		var items []models.UrlData
+		hit := false
+		if cache {
+			seen[path] = true
+			fileCache.Lock()
+			cached, ok := fileCache.files[fileKey{source.Name, path}]
+			fileCache.Unlock()
+			hit = ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size()
+			metrics.CacheLookup("file", hit)
+			items = cached.data
+		}
+		if !hit {
+			fileData, err := readFile(path, source)
+			if err != nil {
+				failed = append(failed, info.Name())
+				continue
+			}
+			items = fileData.UrlData
+			if cache {
+				fileCache.Lock()
+				fileCache.files[fileKey{source.Name, path}] = cachedFile{modTime: info.ModTime(), size: info.Size(), data: items}
+				fileCache.Unlock()
+			}
+		}
+
+		// the item limit applies to the whole directory
+		if len(data.UrlData)+len(items) > source.maxItems() {
+			log.Println("Too many items in: ", dir, source.maxItems())
+			return models.SiteData{}, tooManyItems(source.maxItems())
+		}
+		data.UrlData = append(data.UrlData, items...)
+	}
+
+	// forget the files removed from the directory
+	fileCache.Lock()
+	for key := range fileCache.files {
+		if key.source == source.Name && filepath.Dir(key.path) == dir && !seen[key.path] {
+			delete(fileCache.files, key)
+		}
+	}
+	fileCache.Unlock()
+
+	if len(failed) > 0 {
+		return data, errors.New("Error while reading files in " + dir + ": " + strings.Join(failed, ", "))
+	}
+	return data, nil
+}
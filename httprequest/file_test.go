@@ -0,0 +1,161 @@
+package httprequest
+
+import (
+	"assignment/models"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_filePath(t *testing.T) {
+	tests := []struct {
+		url  string
+		path string
+		ok   bool
+		err  string
+	}{
+		{"file:///data/google.json", "/data/google.json", true, ""},
+		{"file://localhost/data/google.json", "/data/google.json", true, ""},
+		{"file:data/google.json", "data/google.json", true, ""},
+		{"file://data/google.json", "", true, "file url has a host: file://data/google.json, write file:data/google.json for a relative path"},
+		{"https://example.com/google.json", "", false, ""},
+		{"/data/google.json", "", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			path, ok, err := filePath(tt.url)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, filepath.FromSlash(tt.path), path)
+			if tt.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestExecuteAPIFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "google.csv"), "url,views,relevanceScore\nwww.example.com/abc1,1000,0.1\n")
+
+	api := API{BaseURL: "file://" + filepath.ToSlash(filepath.Join(dir, "google.csv"))}
+	data := api.ExecuteAPI()
+	assert.Nil(t, data.URLError)
+	assert.Equal(t, []models.UrlData{
+		{Url: "www.example.com/abc1", Views: 1000, RelevanceScore: 0.1},
+	}, data.UrlData)
+}
+
+func TestExecuteAPIDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.json"), `{"data": [{"url": "www.example.com/abc1", "views": 1000, "relevanceScore": 0.1}]}`)
+	writeFile(t, filepath.Join(dir, "b.json"), `{"data": [{"url": "www.example.com/abc2", "views": 2000, "relevanceScore": 0.2}]}`)
+	writeFile(t, filepath.Join(dir, "notes.txt"), `not a source`)
+
+	api := API{BaseURL: "file://" + filepath.ToSlash(dir)}
+	data := api.ExecuteAPI()
+	assert.Nil(t, data.URLError)
+	assert.Equal(t, []models.UrlData{
+		{Url: "www.example.com/abc1", Views: 1000, RelevanceScore: 0.1},
+		{Url: "www.example.com/abc2", Views: 2000, RelevanceScore: 0.2},
+	}, data.UrlData)
+
+	// changed and removed files are picked up on the next read
+	path := filepath.Join(dir, "a.json")
+	writeFile(t, path, `{"data": [{"url": "www.example.com/abc1", "views": 1500, "relevanceScore": 0.15}]}`)
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "b.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	data = api.ExecuteAPI()
+	assert.Nil(t, data.URLError)
+	assert.Equal(t, []models.UrlData{
+		{Url: "www.example.com/abc1", Views: 1500, RelevanceScore: 0.15},
+	}, data.UrlData)
+}
+
+func TestExecuteAPIDirectorySharedBySources(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.json"), `{"data": [{"url": "www.example.com/abc1", "views": 1000, "hits": 5, "relevanceScore": 0.1}]}`)
+
+	// the sources read the same files with different mappings
+	views := API{BaseURL: "file://" + filepath.ToSlash(dir), Source: Source{Name: "views"}}
+	hits := API{BaseURL: views.BaseURL, Source: Source{Name: "hits", Mapping: Mapping{Views: "/hits"}}}
+	data := views.ExecuteAPI()
+	assert.Nil(t, data.URLError)
+	assert.Equal(t, 1000, data.UrlData[0].Views)
+	data = hits.ExecuteAPI()
+	assert.Nil(t, data.URLError)
+	assert.Equal(t, 5, data.UrlData[0].Views)
+	data = views.ExecuteAPI()
+	assert.Equal(t, 1000, data.UrlData[0].Views)
+}
+
+func TestExecuteAPIDirectoryFeedsDecay(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "feed.atom"), atomFeed)
+	api := API{BaseURL: "file://" + filepath.ToSlash(dir), Source: Source{
+		Name:    "feeds",
+		Pattern: "*.atom",
+		Feed:    FeedOptions{HalfLife: Duration(12 * time.Hour)},
+	}}
+
+	withTimeNow(t, time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC))
+	data := api.ExecuteAPI()
+	assert.Nil(t, data.URLError)
+	assert.Equal(t, 1.0, data.UrlData[0].RelevanceScore)
+
+	// the unchanged feed is read again for the scores to decay
+	withTimeNow(t, time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC))
+	data = api.ExecuteAPI()
+	assert.Nil(t, data.URLError)
+	assert.Equal(t, 0.5, data.UrlData[0].RelevanceScore)
+}
+
+func TestExecuteAPIDirectoryMaxItems(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.json"), `{"data": [{"url": "www.example.com/abc1"}, {"url": "www.example.com/abc2"}]}`)
+	writeFile(t, filepath.Join(dir, "b.json"), `{"data": [{"url": "www.example.com/abc3"}, {"url": "www.example.com/abc4"}]}`)
+
+	// each file is within the limit, but not the directory
+	api := API{BaseURL: "file://" + filepath.ToSlash(dir), Source: Source{Name: "max-items", MaxItems: 3}}
+	data := api.ExecuteAPI()
+	assert.True(t, errors.Is(data.URLError, ErrPayloadTooLarge))
+	assert.EqualError(t, data.URLError, "payload too large: more than 3 items")
+	assert.Empty(t, data.UrlData)
+
+	api.Source.MaxItems = 4
+	data = api.ExecuteAPI()
+	assert.Nil(t, data.URLError)
+	assert.Equal(t, 4, len(data.UrlData))
+}
+
+func Test_readPathErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.ndjson"), `{"url": "www.example.com/abc1", "views": 1000}`)
+	writeFile(t, filepath.Join(dir, "b.ndjson"), `invalid`)
+
+	// the data of the readable files is kept along with the error
+	data, err := readPath(dir, Source{Pattern: "*.ndjson"})
+	assert.EqualError(t, err, "Error while reading files in "+dir+": b.ndjson")
+	assert.Equal(t, []models.UrlData{{Url: "www.example.com/abc1", Views: 1000}}, data.UrlData)
+
+	_, err = readPath(filepath.Join(dir, "missing.json"), Source{})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,189 @@
+package httprequest
+
+import (
+	"assignment/models"
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHedgePercentile = 0.95
+	defaultHedgeMinDelay   = 50 * time.Millisecond
+	defaultHedgeBudget     = 0.1
+	// hedgeWindow is the number of recent requests the delay and the budget are computed on
+	hedgeWindow = 100
+	// minHedgeSamples is the number of latencies needed before hedging
+	minHedgeSamples = 10
+)
+
+// HedgeOptions configures hedged requests: when the first attempt of a request
+// is slower than the Percentile of the recent latencies of the source, a second
+// one is sent and the first response wins
+type HedgeOptions struct {
+	Enabled bool `json:"enabled"`
+	// Percentile of the recent latencies after which a second request is sent, default 0.95
+	Percentile float64 `json:"percentile"`
+	// MinDelay is the minimum delay before a second request, default 50ms
+	MinDelay Duration `json:"minDelay"`
+	// Budget is the maximum fraction of the requests that are hedged, default 0.1
+	Budget float64 `json:"budget"`
+	// Idempotent allows hedging the requests of a source whose method is not GET or HEAD
+	Idempotent bool `json:"idempotent"`
+}
+
+func (o HedgeOptions) check() error {
+	if o.Percentile < 0 || o.Percentile >= 1 {
+		return errors.New("hedge 'percentile' must be between 0 and 1")
+	}
+	if o.Budget < 0 || o.Budget > 1 {
+		return errors.New("hedge 'budget' must be between 0 and 1")
+	}
+	return nil
+}
+
+// hedger keeps the recent latencies and hedges of a source
+type hedger struct {
+	sync.Mutex
+	latencies []time.Duration
+	hedged    []bool
+	next      int
+}
+
+// hedgers keeps the hedgers by url, the mirrors of a source having their own
+var hedgers = struct {
+	sync.Mutex
+	m map[string]*hedger
+}{m: make(map[string]*hedger)}
+
+func hedgerFor(url string) *hedger {
+	hedgers.Lock()
+	defer hedgers.Unlock()
+	h, ok := hedgers.m[url]
+	if !ok {
+		h = &hedger{}
+		hedgers.m[url] = h
+	}
+	return h
+}
+
+// delay returns how long to wait for the first attempt before hedging,
+// false when there are not enough latencies yet
+func (h *hedger) delay(options HedgeOptions) (time.Duration, bool) {
+	h.Lock()
+	defer h.Unlock()
+	if len(h.latencies) < minHedgeSamples {
+		return 0, false
+	}
+	percentile := options.Percentile
+	if percentile == 0 {
+		percentile = defaultHedgePercentile
+	}
+	sorted := append([]time.Duration(nil), h.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	delay := sorted[int(math.Ceil(percentile*float64(len(sorted))))-1]
+
+	minDelay := time.Duration(options.MinDelay)
+	if minDelay == 0 {
+		minDelay = defaultHedgeMinDelay
+	}
+	if delay < minDelay {
+		delay = minDelay
+	}
+	return delay, true
+}
+
+// allow reports whether one more hedge stays within the budget of the recent requests
+func (h *hedger) allow(options HedgeOptions) bool {
+	h.Lock()
+	defer h.Unlock()
+	budget := options.Budget
+	if budget == 0 {
+		budget = defaultHedgeBudget
+	}
+	hedges := 1
+	for _, hedged := range h.hedged {
+		if hedged {
+			hedges++
+		}
+	}
+	return float64(hedges) <= budget*float64(len(h.hedged)+1)
+}
+
+// record adds the latency of a request and whether it was hedged
+func (h *hedger) record(latency time.Duration, hedged bool) {
+	h.Lock()
+	defer h.Unlock()
+	if len(h.latencies) < hedgeWindow {
+		h.latencies = append(h.latencies, latency)
+		h.hedged = append(h.hedged, hedged)
+		return
+	}
+	h.latencies[h.next] = latency
+	h.hedged[h.next] = hedged
+	h.next = (h.next + 1) % hedgeWindow
+}
+
+type attempt struct {
+	data models.SiteData
+	err  error
+}
+
+// hedgeable reports whether the requests of the source may be sent twice
+func (o HedgeOptions) hedgeable(method string) bool {
+	return o.Enabled && (method == http.MethodGet || method == http.MethodHead || o.Idempotent)
+}
+
+// hedgedFetch is fetch, sending a second request when the first one is slow.
+// The first successful response wins and the other request is canceled.
+// Only the latency of the first request is recorded, measured from its start
+// even when the second one wins, so that hedges do not lower the delay.
+func (api *API) hedgedFetch(ctx context.Context) (models.SiteData, error) {
+	options := api.Source.Hedge
+	if _, ok, _ := filePath(api.BaseURL); !options.hedgeable(api.Source.method()) || ok {
+		return api.fetch(ctx)
+	}
+	h := hedgerFor(api.BaseURL)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	attempts := make(chan attempt, 2)
+	send := func() {
+		data, err := api.fetch(ctx)
+		attempts <- attempt{data: data, err: err}
+	}
+	start := time.Now()
+	go send()
+
+	var hedge <-chan time.Time
+	if delay, ok := h.delay(options); ok {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		hedge = timer.C
+	}
+
+	running, hedged := 1, false
+	var last attempt
+	for running > 0 {
+		select {
+		case <-hedge:
+			hedge = nil
+			if h.allow(options) {
+				hedged = true
+				running++
+				go send()
+			}
+		case last = <-attempts:
+			running--
+			if last.err == nil {
+				h.record(time.Since(start), hedged)
+				return last.data, nil
+			}
+		}
+	}
+	return last.data, last.err
+}
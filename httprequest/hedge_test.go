@@ -0,0 +1,145 @@
+package httprequest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// primedHedger returns the hedger of url with n recent latencies of latency
+func primedHedger(t *testing.T, url string, n int, latency time.Duration) *hedger {
+	t.Cleanup(func() {
+		hedgers.Lock()
+		delete(hedgers.m, url)
+		hedgers.Unlock()
+	})
+	h := hedgerFor(url)
+	for i := 0; i < n; i++ {
+		h.record(latency, false)
+	}
+	return h
+}
+
+// slowFirstServer delays its first response by delay and counts the requests
+func slowFirstServer(t *testing.T, delay time.Duration, hits *int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(hits, 1) == 1 {
+			select {
+			case <-time.After(delay):
+			case <-req.Context().Done():
+				return
+			}
+		}
+		rw.Write([]byte(authBody))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestExecuteAPIHedged(t *testing.T) {
+	var hits int32
+	server := slowFirstServer(t, time.Second, &hits)
+	h := primedHedger(t, server.URL, 50, time.Millisecond)
+
+	api := API{Client: server.Client(), BaseURL: server.URL, Source: Source{
+		Name:  "hedged",
+		Hedge: HedgeOptions{Enabled: true, Budget: 0.5},
+	}}
+	start := time.Now()
+	data := api.ExecuteAPI()
+	assert.Nil(t, data.URLError)
+	assert.Len(t, data.UrlData, 1)
+	assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	assert.True(t, h.hedged[len(h.hedged)-1])
+	// the latency of the slow first request is recorded, not the one of the hedge
+	assert.GreaterOrEqual(t, int64(h.latencies[len(h.latencies)-1]), int64(defaultHedgeMinDelay))
+}
+
+func TestExecuteAPIHedgeMethods(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		hedge  HedgeOptions
+		hits   int32
+	}{
+		{"TestHead", "HEAD", HedgeOptions{Enabled: true, Budget: 0.5}, 2},
+		{"TestPost", "POST", HedgeOptions{Enabled: true, Budget: 0.5}, 1},
+		{"TestIdempotentPost", "POST", HedgeOptions{Enabled: true, Budget: 0.5, Idempotent: true}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits int32
+			server := slowFirstServer(t, 300*time.Millisecond, &hits)
+			primedHedger(t, server.URL, 50, time.Millisecond)
+
+			api := API{Client: server.Client(), BaseURL: server.URL, Source: Source{Method: tt.method, Hedge: tt.hedge}}
+			api.hedgedFetch(context.Background())
+			assert.Equal(t, tt.hits, atomic.LoadInt32(&hits))
+		})
+	}
+}
+
+func TestExecuteAPIHedgeBudget(t *testing.T) {
+	var hits int32
+	server := slowFirstServer(t, 200*time.Millisecond, &hits)
+	h := primedHedger(t, server.URL, 10, time.Millisecond)
+	for i := range h.hedged {
+		h.hedged[i] = i%2 == 0
+	}
+
+	api := API{Client: server.Client(), BaseURL: server.URL, Source: Source{
+		Name:  "budget",
+		Hedge: HedgeOptions{Enabled: true, Budget: 0.1},
+	}}
+	data := api.ExecuteAPI()
+	assert.Nil(t, data.URLError)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestExecuteAPIHedgeWithoutHistory(t *testing.T) {
+	var hits int32
+	server := slowFirstServer(t, 100*time.Millisecond, &hits)
+	primedHedger(t, server.URL, minHedgeSamples-1, time.Millisecond)
+
+	api := API{Client: server.Client(), BaseURL: server.URL, Source: Source{
+		Name:  "history",
+		Hedge: HedgeOptions{Enabled: true, Budget: 1},
+	}}
+	data := api.ExecuteAPI()
+	assert.Nil(t, data.URLError)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestHedgerDelay(t *testing.T) {
+	h := &hedger{}
+	for i := 1; i <= 100; i++ {
+		h.record(time.Duration(i)*time.Millisecond, false)
+	}
+	delay, ok := h.delay(HedgeOptions{})
+	assert.True(t, ok)
+	assert.Equal(t, 95*time.Millisecond, delay)
+	delay, _ = h.delay(HedgeOptions{Percentile: 0.5})
+	assert.Equal(t, 50*time.Millisecond, delay)
+	delay, _ = h.delay(HedgeOptions{Percentile: 0.1, MinDelay: Duration(20 * time.Millisecond)})
+	assert.Equal(t, 20*time.Millisecond, delay)
+
+	// the window keeps the latest latencies
+	for i := 0; i < hedgeWindow; i++ {
+		h.record(time.Second, false)
+	}
+	delay, _ = h.delay(HedgeOptions{Percentile: 0.5})
+	assert.Equal(t, time.Second, delay)
+}
+
+func TestHedgeOptionsCheck(t *testing.T) {
+	assert.Nil(t, HedgeOptions{}.check())
+	assert.Nil(t, HedgeOptions{Enabled: true, Percentile: 0.99, Budget: 0.05}.check())
+	assert.EqualError(t, HedgeOptions{Percentile: 1}.check(), "hedge 'percentile' must be between 0 and 1")
+	assert.EqualError(t, HedgeOptions{Budget: 2}.check(), "hedge 'budget' must be between 0 and 1")
+}
@@ -0,0 +1,64 @@
+package httprequest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	defaultMaxBodySize = 10 << 20 // 10 MiB
+	defaultMaxItems    = 100000
+)
+
+// ErrPayloadTooLarge is returned when a source response exceeds its maximum
+// body size or maximum item count
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// maxBodySize returns the maximum body size of the source responses in bytes
+func (s Source) maxBodySize() int64 {
+	if s.MaxBodySize > 0 {
+		return s.MaxBodySize
+	}
+	return defaultMaxBodySize
+}
+
+// maxItems returns the maximum number of items in the source responses
+func (s Source) maxItems() int {
+	if s.MaxItems > 0 {
+		return s.MaxItems
+	}
+	return defaultMaxItems
+}
+
+func bodyTooLarge(max int64) error {
+	return fmt.Errorf("%w: body exceeds %d bytes", ErrPayloadTooLarge, max)
+}
+
+func tooManyItems(max int) error {
+	return fmt.Errorf("%w: more than %d items", ErrPayloadTooLarge, max)
+}
+
+// limitedReader reads at most max bytes from r and fails with
+// ErrPayloadTooLarge as soon as r has more
+type limitedReader struct {
+	r    io.Reader
+	max  int64
+	read int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.read > l.max {
+		return 0, bodyTooLarge(l.max)
+	}
+	// read one byte past max to detect bodies larger than max
+	if remaining := l.max - l.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.max {
+		return n - int(l.read-l.max), bodyTooLarge(l.max)
+	}
+	return n, err
+}
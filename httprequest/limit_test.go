@@ -0,0 +1,116 @@
+package httprequest
+
+import (
+	"assignment/models"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func itemsJSON(n int) string {
+	items := make([]string, n)
+	for i := range items {
+		items[i] = fmt.Sprintf(`{"url": "www.example.com/abc%d", "views": %d, "relevanceScore": 0.1}`, i, i)
+	}
+	return `{"data": [` + strings.Join(items, ",") + `]}`
+}
+
+func TestExecuteAPIPayloadTooLarge(t *testing.T) {
+	tests := []struct {
+		name          string
+		source        Source
+		contentLength bool
+	}{
+		{"TestMaxItems", Source{MaxItems: 5}, true},
+		{"TestMaxBodySize", Source{MaxBodySize: 200}, false},
+		{"TestMaxBodySizeContentLength", Source{MaxBodySize: 200}, true},
+		{"TestMaxItemsCSV", Source{Format: "csv", MaxItems: 1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests = 0
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				requests++
+				body := itemsJSON(10)
+				if tt.source.Format == "csv" {
+					body = "url\nwww.example.com/abc1\nwww.example.com/abc2\n"
+				}
+				if !tt.contentLength {
+					// flushing before writing forces a chunked response without Content-Length
+					rw.(http.Flusher).Flush()
+				}
+				rw.Write([]byte(body))
+			}))
+			defer server.Close()
+
+			api := API{Client: server.Client(), BaseURL: server.URL, Source: tt.source}
+			data := api.ExecuteAPI()
+
+			assert.True(t, errors.Is(data.URLError, ErrPayloadTooLarge))
+			assert.Equal(t, 0, len(data.UrlData))
+			// not retried
+			assert.Equal(t, 1, requests)
+		})
+	}
+}
+
+func Test_decodeJSONWithinLimits(t *testing.T) {
+	body := itemsJSON(5)
+	source := Source{MaxItems: 5, MaxBodySize: int64(len(body))}
+	data, err := decodeJSON(&limitedReader{r: strings.NewReader(body), max: source.maxBodySize()}, source)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(data))
+}
+
+func Test_decodeJSONSeek(t *testing.T) {
+	body := `{
+		"meta": {"data": [1, 2, 3], "nested": [{"a": {}}, []]},
+		"pages": [
+			{"results": []},
+			{"results": [{"link": "www.example.com/abc1", "hits": 10}]}
+		],
+		"after": "ignored"
+	}`
+	tests := []struct {
+		items string
+		data  []models.UrlData
+	}{
+		{"$.pages[1].results", []models.UrlData{{Url: "www.example.com/abc1", Views: 10}}},
+		{"/pages/0/results", nil},
+		{"/pages/5/results", nil},
+		{"/missing", nil},
+		{"/after/results", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.items, func(t *testing.T) {
+			source := Source{Mapping: Mapping{Items: tt.items, Url: "/link", Views: "/hits"}}
+			data, err := decodeJSON(strings.NewReader(body), source)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.data, data)
+		})
+	}
+
+	_, err := decodeJSON(strings.NewReader(`{"data": [{"url": "a"}, `), Source{})
+	assert.NotNil(t, err)
+	_, err = decodeJSON(strings.NewReader(`{"data": {"url": "a"}}`), Source{})
+	assert.EqualError(t, err, "items are not an array")
+	data, err := decodeJSON(strings.NewReader(`{"data": null}`), Source{})
+	assert.Nil(t, err)
+	assert.Nil(t, data)
+}
+
+func Test_limitedReader(t *testing.T) {
+	body, err := ioutil.ReadAll(&limitedReader{r: strings.NewReader("0123456789"), max: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, "0123456789", string(body))
+
+	body, err = ioutil.ReadAll(&limitedReader{r: strings.NewReader("0123456789"), max: 9})
+	assert.True(t, errors.Is(err, ErrPayloadTooLarge))
+	assert.Equal(t, "012345678", string(body))
+}
@@ -0,0 +1,200 @@
+package httprequest
+
+import (
+	"assignment/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Mapping locates the items and their fields in the JSON of a source.
+// Each path is either a JSON pointer ("/results/0/link") or a JSONPath of
+// names and indexes ("$.results[0].link", "$['results'][0]['link']").
+// Item fields are relative to the item. String values are coerced to numbers.
+type Mapping struct {
+	Items          string `json:"items"`          // default "/data"
+	Url            string `json:"url"`            // default "/url"
+	Views          string `json:"views"`          // default "/views"
+	RelevanceScore string `json:"relevanceScore"` // default "/relevanceScore"
+}
+
+// compiledMapping is a Mapping with its paths parsed into segments
+type compiledMapping struct {
+	items, url, views, relevanceScore []string
+}
+
+func (m Mapping) compile() (compiledMapping, error) {
+	var c compiledMapping
+	paths := []struct {
+		expr, def string
+		dst       *[]string
+	}{
+		{m.Items, "/data", &c.items},
+		{m.Url, "/url", &c.url},
+		{m.Views, "/views", &c.views},
+		{m.RelevanceScore, "/relevanceScore", &c.relevanceScore},
+	}
+	for _, p := range paths {
+		expr := p.expr
+		if expr == "" {
+			expr = p.def
+		}
+		path, err := parsePath(expr)
+		if err != nil {
+			return c, err
+		}
+		*p.dst = path
+	}
+	return c, nil
+}
+
+func (m Mapping) check() error {
+	_, err := m.compile()
+	return err
+}
+
+// item converts a single decoded JSON item into url data
+func (c compiledMapping) item(item interface{}) (models.UrlData, error) {
+	var data models.UrlData
+	var err error
+	if v, ok := lookup(item, c.url); ok {
+		if data.Url, err = toString(v); err != nil {
+			return data, errors.New("url: " + err.Error())
+		}
+	}
+	if v, ok := lookup(item, c.views); ok {
+		if data.Views, err = toInt(v); err != nil {
+			return data, errors.New("views: " + err.Error())
+		}
+	}
+	if v, ok := lookup(item, c.relevanceScore); ok {
+		if data.RelevanceScore, err = toFloat(v); err != nil {
+			return data, errors.New("relevanceScore: " + err.Error())
+		}
+	}
+	return data, nil
+}
+
+// parsePath parses a JSON pointer or a JSONPath into its segments
+func parsePath(expr string) ([]string, error) {
+	switch {
+	case expr == "" || expr == "$":
+		return nil, nil
+	case strings.HasPrefix(expr, "/"):
+		segments := strings.Split(expr[1:], "/")
+		for i, segment := range segments {
+			segments[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(segment)
+		}
+		return segments, nil
+	case strings.HasPrefix(expr, "$"):
+		return parseJSONPath(expr)
+	}
+	return nil, errors.New("invalid path, must start with '/' or '$': " + expr)
+}
+
+func parseJSONPath(expr string) ([]string, error) {
+	var segments []string
+	rest := expr[1:]
+	for rest != "" {
+		switch {
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			if end == 0 {
+				return nil, errors.New("invalid path, empty name: " + expr)
+			}
+			segments = append(segments, rest[1:end+1])
+			rest = rest[end+1:]
+		case strings.HasPrefix(rest, "['"):
+			end := strings.Index(rest, "']")
+			if end < 0 {
+				return nil, errors.New("invalid path, unclosed bracket: " + expr)
+			}
+			segments = append(segments, rest[2:end])
+			rest = rest[end+2:]
+		case rest[0] == '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, errors.New("invalid path, unclosed bracket: " + expr)
+			}
+			if _, err := strconv.Atoi(rest[1:end]); err != nil {
+				return nil, errors.New("invalid path, bad index: " + expr)
+			}
+			segments = append(segments, rest[1:end])
+			rest = rest[end+1:]
+		default:
+			return nil, errors.New("invalid path: " + expr)
+		}
+	}
+	return segments, nil
+}
+
+// lookup follows path in a decoded JSON value
+func lookup(v interface{}, path []string) (interface{}, bool) {
+	for _, segment := range path {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			child, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			v = child
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func toString(v interface{}) (string, error) {
+	switch value := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	}
+	return "", fmt.Errorf("cannot convert %T to a string", v)
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch value := v.(type) {
+	case nil:
+		return 0, nil
+	case json.Number:
+		return value.Float64()
+	case float64:
+		return value, nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(value), 64)
+	}
+	return 0, fmt.Errorf("cannot convert %T to a number", v)
+}
+
+func toInt(v interface{}) (int, error) {
+	if number, ok := v.(json.Number); ok {
+		if i, err := number.Int64(); err == nil {
+			return int(i), nil
+		}
+	}
+	f, err := toFloat(v)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, errors.New("not a finite number")
+	}
+	return int(math.Round(f)), nil
+}
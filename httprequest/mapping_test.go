@@ -0,0 +1,102 @@
+package httprequest
+
+import (
+	"assignment/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parsePath(t *testing.T) {
+	tests := []struct {
+		expr    string
+		path    []string
+		wantErr bool
+	}{
+		{"/data", []string{"data"}, false},
+		{"/results/0/link", []string{"results", "0", "link"}, false},
+		{"/a~1b/c~0d", []string{"a/b", "c~d"}, false},
+		{"$", nil, false},
+		{"$.results", []string{"results"}, false},
+		{"$.results[0].link", []string{"results", "0", "link"}, false},
+		{"$['results'][1]['the link']", []string{"results", "1", "the link"}, false},
+		{"results", nil, true},
+		{"$..results", nil, true},
+		{"$.results[abc]", nil, true},
+		{"$['results'", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			path, err := parsePath(tt.expr)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.path, path)
+		})
+	}
+}
+
+func TestExecuteAPIMapping(t *testing.T) {
+	// Start a local HTTP server
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`{
+			"response": {
+				"results": [
+					{"link": "www.example.com/abc1", "stats": {"hits": "1200"}, "score": "0.25"},
+					{"link": "www.example.com/abc2", "stats": {"hits": 1300.4}, "score": 0.5},
+					{"link": "www.example.com/abc3", "stats": {}}
+				]
+			}
+		}`))
+	}))
+	defer server.Close()
+
+	api := API{
+		Client:  server.Client(),
+		BaseURL: server.URL,
+		Source: Source{
+			Mapping: Mapping{
+				Items:          "$.response.results",
+				Url:            "/link",
+				Views:          "$.stats.hits",
+				RelevanceScore: "/score",
+			},
+		},
+	}
+	data := api.ExecuteAPI()
+
+	assert.Nil(t, data.URLError)
+	assert.Equal(t, []models.UrlData{
+		{Url: "www.example.com/abc1", Views: 1200, RelevanceScore: 0.25},
+		{Url: "www.example.com/abc2", Views: 1300, RelevanceScore: 0.5},
+		{Url: "www.example.com/abc3"},
+	}, data.UrlData)
+}
+
+func TestExecuteAPIMappingInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"TestItemsNotArray", `{"results": {"link": "www.example.com/abc1"}}`},
+		{"TestViewsNotNumber", `{"results": [{"link": "www.example.com/abc1", "hits": "many"}]}`},
+		{"TestViewsObject", `{"results": [{"link": "www.example.com/abc1", "hits": {}}]}`},
+		{"TestUrlNotString", `{"results": [{"link": true}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			resp, err := server.Client().Get(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			source := Source{Mapping: Mapping{Items: "/results", Url: "/link", Views: "/hits"}}
+			_, err = parseResponse(server.URL, resp, source)
+			assert.NotNil(t, err)
+		})
+	}
+}
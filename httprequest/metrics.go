@@ -0,0 +1,56 @@
+package httprequest
+
+import (
+	"assignment/metrics"
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+)
+
+// Classes of the errors counted by metrics.Errors
+const (
+	errorTimeout    = "timeout"
+	errorCanceled   = "canceled"
+	errorConnection = "connection"
+	errorStatus     = "status"
+	errorDecode     = "decode"
+	errorTooLarge   = "payload_too_large"
+	errorFile       = "file"
+	errorOther      = "other"
+)
+
+// countAttempt counts a request to the source, and its error if any
+func countAttempt(source string, err error) {
+	metrics.Attempts.WithLabelValues(source).Inc()
+	if err != nil {
+		metrics.Errors.WithLabelValues(source, errorClass(err)).Inc()
+	}
+}
+
+// errorClass sorts the errors of a fetch into a few classes for the metrics
+func errorClass(err error) string {
+	var netErr net.Error
+	var statusErr *statusError
+	var decodeErr *decodeError
+	var urlErr *url.Error
+	var pathErr *os.PathError
+	switch {
+	case errors.Is(err, ErrPayloadTooLarge):
+		return errorTooLarge
+	case errors.Is(err, context.Canceled):
+		return errorCanceled
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return errorTimeout
+	case errors.As(err, &statusErr):
+		return errorStatus
+	case errors.As(err, &decodeErr):
+		return errorDecode
+	case errors.As(err, &urlErr):
+		return errorConnection
+	case errors.As(err, &pathErr):
+		return errorFile
+	}
+	return errorOther
+}
@@ -0,0 +1,88 @@
+package httprequest
+
+import (
+	"assignment/metrics"
+	"assignment/models"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		err   error
+		class string
+	}{
+		{bodyTooLarge(10), errorTooLarge},
+		{&decodeError{tooManyItems(10)}, errorTooLarge},
+		{context.Canceled, errorCanceled},
+		{context.DeadlineExceeded, errorTimeout},
+		{&statusError{code: 500, url: "http://example.com"}, errorStatus},
+		{&decodeError{errors.New("items are not an array")}, errorDecode},
+		{&os.PathError{Op: "open", Path: "a.json", Err: os.ErrNotExist}, errorFile},
+		{errors.New("secret environment variable is not set: X"), errorOther},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.class, errorClass(tt.err), tt.err.Error())
+	}
+}
+
+func TestExecuteAPIMetrics(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+		if calls == 1 {
+			rw.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		rw.Write([]byte(`{"data": "invalid"}`))
+	}))
+	defer server.Close()
+	defer func(retries int) { Retries = retries }(Retries)
+	Retries = 2
+	defer func(delay time.Duration) { backoff = delay }(backoff)
+	backoff = time.Millisecond
+
+	name := "metrics-test"
+	api := API{Client: server.Client(), BaseURL: server.URL, Source: Source{Name: name}}
+	data := api.ExecuteAPI()
+	assert.NotNil(t, data.URLError)
+	assert.EqualError(t, data.URLError, "items are not an array")
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.Attempts.WithLabelValues(name)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.Retries.WithLabelValues(name)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.Errors.WithLabelValues(name, errorStatus)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.Errors.WithLabelValues(name, errorDecode)))
+}
+
+func TestGetContentItemsMetric(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.json")
+	if err := ioutil.WriteFile(path, []byte(authBody), 0644); err != nil {
+		t.Fatal(err)
+	}
+	name := "metrics-items-test"
+	t.Cleanup(func() {
+		statuses.Lock()
+		delete(statuses.m, name)
+		statuses.Unlock()
+	})
+
+	siteData := make(chan models.SiteData, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	getContent(context.Background(), Source{Name: name, URL: "file://" + path}, siteData, &wg)
+	assert.Nil(t, (<-siteData).URLError)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.Items.WithLabelValues(name)))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.FetchDuration.WithLabelValues(name).(prometheus.Histogram)))
+}
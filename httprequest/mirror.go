@@ -0,0 +1,98 @@
+package httprequest
+
+import (
+	"assignment/models"
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// primaryRecheck is how long a source keeps to a healthy mirror before its url is tried first again
+const primaryRecheck = 5 * time.Minute
+
+// activeMirror is the index of the url of a source that last succeeded
+type activeMirror struct {
+	index int
+	url   string
+	since time.Time
+}
+
+// mirrors keeps the healthy urls of the sources by name
+var mirrors = struct {
+	sync.Mutex
+	m map[string]activeMirror
+}{m: make(map[string]activeMirror)}
+
+// activeURL returns the mirror url in use by a source, false while it uses its own url
+func activeURL(name string) (string, bool) {
+	mirrors.Lock()
+	defer mirrors.Unlock()
+	active, ok := mirrors.m[name]
+	if !ok || active.index == 0 {
+		return "", false
+	}
+	return active.url, true
+}
+
+// firstMirror returns the index of the url to try first
+func firstMirror(name string, count int) int {
+	mirrors.Lock()
+	defer mirrors.Unlock()
+	active, ok := mirrors.m[name]
+	if !ok || active.index >= count || timeNow().Sub(active.since) > primaryRecheck {
+		return 0
+	}
+	return active.index
+}
+
+// setMirror records the url of the source that succeeded. The time is only
+// reset when it differs from the recorded url or from the url tried first.
+func setMirror(name string, index int, url string, tried int) {
+	mirrors.Lock()
+	defer mirrors.Unlock()
+	if active, ok := mirrors.m[name]; ok && active.index == index && index == tried {
+		return
+	}
+	mirrors.m[name] = activeMirror{index: index, url: url, since: timeNow()}
+}
+
+// fetchMirrors fetches BaseURL, failing over to the mirrors of the source in
+// order. The url that succeeds is tried first by the next fetches.
+func (api *API) fetchMirrors(ctx context.Context) (models.SiteData, error) {
+	if len(api.Source.Mirrors) == 0 {
+		return api.hedgedFetch(ctx)
+	}
+	urls := append([]string{api.BaseURL}, api.Source.Mirrors...)
+	first := firstMirror(api.Source.Name, len(urls))
+
+	var data models.SiteData
+	var err error
+	for i := 0; i < len(urls); i++ {
+		index := (first + i) % len(urls)
+		mirror := *api
+		mirror.BaseURL = urls[index]
+		data, err = mirror.hedgedFetch(ctx)
+		if err == nil {
+			if index != first {
+				log.Println("Switching to mirror: ", api.Source.Name, urls[index])
+			}
+			setMirror(api.Source.Name, index, urls[index], first)
+			return data, nil
+		}
+		if errors.Is(err, ErrPayloadTooLarge) || ctx.Err() != nil {
+			return data, err
+		}
+	}
+	return data, err
+}
+
+func checkMirrors(mirrors []string) error {
+	for _, mirror := range mirrors {
+		if mirror == "" {
+			return errors.New("empty url in 'mirrors'")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package httprequest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// toggleServer serves authBody while healthy is set, and an error otherwise
+func toggleServer(t *testing.T, healthy *int32, hits *int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(hits, 1)
+		if atomic.LoadInt32(healthy) == 0 {
+			rw.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		rw.Write([]byte(authBody))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func forgetMirror(t *testing.T, name string) {
+	t.Cleanup(func() {
+		mirrors.Lock()
+		delete(mirrors.m, name)
+		mirrors.Unlock()
+	})
+}
+
+func TestExecuteAPIMirrors(t *testing.T) {
+	defer func() { timeNow = time.Now }()
+	start := time.Now()
+	timeNow = func() time.Time { return start }
+	forgetMirror(t, "mirrored")
+
+	var primaryHealthy, primaryHits, cdnHits int32
+	primary := toggleServer(t, &primaryHealthy, &primaryHits)
+	cdnHealthy := int32(0)
+	cdn := toggleServer(t, &cdnHealthy, &cdnHits)
+	mirrorFile := filepath.Join(t.TempDir(), "google.json")
+	writeFile(t, mirrorFile, authBody)
+
+	api := API{Client: primary.Client(), BaseURL: primary.URL, Source: Source{
+		Name:    "mirrored",
+		Mirrors: []string{cdn.URL, "file://" + filepath.ToSlash(mirrorFile)},
+	}}
+
+	// the primary and the cdn fail, the local file is used
+	data := api.ExecuteAPI()
+	assert.Nil(t, data.URLError)
+	assert.Len(t, data.UrlData, 1)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&primaryHits))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&cdnHits))
+	url, ok := activeURL("mirrored")
+	assert.True(t, ok)
+	assert.Equal(t, "file://"+filepath.ToSlash(mirrorFile), url)
+
+	// and remembered as healthy
+	data = api.ExecuteAPI()
+	assert.Nil(t, data.URLError)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&primaryHits))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&cdnHits))
+
+	// until the primary is tried again
+	atomic.StoreInt32(&primaryHealthy, 1)
+	timeNow = func() time.Time { return start.Add(primaryRecheck + time.Second) }
+	data = api.ExecuteAPI()
+	assert.Nil(t, data.URLError)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&primaryHits))
+	_, ok = activeURL("mirrored")
+	assert.False(t, ok)
+}
+
+func TestExecuteAPIMirrorsRecheckFailed(t *testing.T) {
+	defer func() { timeNow = time.Now }()
+	start := time.Now()
+	timeNow = func() time.Time { return start }
+	forgetMirror(t, "recheck")
+
+	var primaryHealthy, primaryHits, cdnHits int32
+	cdnHealthy := int32(1)
+	primary := toggleServer(t, &primaryHealthy, &primaryHits)
+	cdn := toggleServer(t, &cdnHealthy, &cdnHits)
+	api := API{Client: primary.Client(), BaseURL: primary.URL, Source: Source{
+		Name:    "recheck",
+		Mirrors: []string{cdn.URL},
+	}}
+
+	api.ExecuteAPI()
+	timeNow = func() time.Time { return start.Add(primaryRecheck + time.Second) }
+	api.ExecuteAPI()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&primaryHits))
+
+	// the failed recheck keeps the cdn for another period
+	api.ExecuteAPI()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&primaryHits))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&cdnHits))
+}
+
+func Test_checkMirrors(t *testing.T) {
+	assert.Nil(t, checkMirrors(nil))
+	assert.Nil(t, checkMirrors([]string{"https://cdn.example.com/google.json"}))
+	assert.EqualError(t, checkMirrors([]string{""}), "empty url in 'mirrors'")
+}
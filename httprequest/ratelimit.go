@@ -0,0 +1,121 @@
+package httprequest
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimit limits the requests sent to the host of a source with a token bucket
+type RateLimit struct {
+	// Rate is the number of requests per second, 0 for no limit
+	Rate float64 `json:"rate"`
+	// Burst is the number of requests sent at once, default 1
+	Burst int `json:"burst"`
+}
+
+func (r RateLimit) burst() int {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	return 1
+}
+
+func (r RateLimit) check() error {
+	if r.Rate < 0 {
+		return errors.New("rateLimit 'rate' must not be negative")
+	}
+	if r.Burst < 0 {
+		return errors.New("rateLimit 'burst' must not be negative")
+	}
+	return nil
+}
+
+// limiters keeps the token bucket of each host, shared by every source of the host
+var limiters = struct {
+	sync.Mutex
+	m map[string]*rate.Limiter
+}{m: make(map[string]*rate.Limiter)}
+
+// hosts returns the hosts of the url and the mirrors of the source
+func (s Source) hosts() []string {
+	var hosts []string
+	for _, rawURL := range append([]string{s.URL}, s.Mirrors...) {
+		if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+			hosts = append(hosts, u.Host)
+		}
+	}
+	return hosts
+}
+
+// checkRateLimits rejects the sources setting different rate limits for the same host
+func checkRateLimits(sources []Source) error {
+	limited := make(map[string]Source)
+	for _, source := range sources {
+		if source.RateLimit.Rate == 0 {
+			continue
+		}
+		for _, host := range source.hosts() {
+			other, ok := limited[host]
+			if !ok {
+				limited[host] = source
+				continue
+			}
+			if other.RateLimit.Rate != source.RateLimit.Rate || other.RateLimit.burst() != source.RateLimit.burst() {
+				return errors.New("source " + source.Name + ": 'rateLimit' differs from the one of source " + other.Name + " on host " + host)
+			}
+		}
+	}
+	return nil
+}
+
+// SetRateLimits sets the token buckets of the hosts of the sources to their
+// rate limit, which then applies to every source of the host, limited or not.
+// The buckets whose limit is unchanged keep their tokens.
+func SetRateLimits(sources []Source) {
+	limiters.Lock()
+	defer limiters.Unlock()
+	m := make(map[string]*rate.Limiter)
+	for _, source := range sources {
+		if source.RateLimit.Rate == 0 {
+			continue
+		}
+		for _, host := range source.hosts() {
+			if _, ok := m[host]; ok {
+				continue
+			}
+			limiter, ok := limiters.m[host]
+			if !ok || limiter.Limit() != rate.Limit(source.RateLimit.Rate) || limiter.Burst() != source.RateLimit.burst() {
+				limiter = rate.NewLimiter(rate.Limit(source.RateLimit.Rate), source.RateLimit.burst())
+			}
+			m[host] = limiter
+		}
+	}
+	limiters.m = m
+}
+
+// limiterFor returns the token bucket of host. A host without one gets the
+// limit of the source, if any, and keeps it for the other sources of the host.
+func limiterFor(host string, limit RateLimit) *rate.Limiter {
+	limiters.Lock()
+	defer limiters.Unlock()
+	limiter, ok := limiters.m[host]
+	if !ok && limit.Rate != 0 {
+		limiter = rate.NewLimiter(rate.Limit(limit.Rate), limit.burst())
+		limiters.m[host] = limiter
+	}
+	return limiter
+}
+
+// waitRate blocks until the rate limit of host allows a request of the source.
+// It fails at once when ctx would be done before then.
+func waitRate(ctx context.Context, source Source, host string) error {
+	limiter := limiterFor(host, source.RateLimit)
+	if limiter == nil {
+		return nil
+	}
+	return limiter.Wait(ctx)
+}
@@ -0,0 +1,152 @@
+package httprequest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+)
+
+func forgetLimiter(t *testing.T, rawURL string) {
+	u, _ := url.Parse(rawURL)
+	t.Cleanup(func() {
+		limiters.Lock()
+		delete(limiters.m, u.Host)
+		limiters.Unlock()
+	})
+}
+
+func TestExecuteAPIRateLimit(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		rw.Write([]byte(authBody))
+	}))
+	defer server.Close()
+	forgetLimiter(t, server.URL)
+
+	api := API{Client: server.Client(), BaseURL: server.URL, Source: Source{
+		RateLimit: RateLimit{Rate: 10, Burst: 2},
+	}}
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		assert.Nil(t, api.ExecuteAPI().URLError)
+	}
+	// the burst goes at once, the next two wait 100ms each
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(150*time.Millisecond))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&hits))
+}
+
+func TestExecuteAPIRateLimitDeadline(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		rw.Write([]byte(authBody))
+	}))
+	defer server.Close()
+	forgetLimiter(t, server.URL)
+
+	api := API{Client: server.Client(), BaseURL: server.URL, Source: Source{
+		RateLimit: RateLimit{Rate: 0.1},
+	}}
+	assert.Nil(t, api.ExecuteAPI().URLError)
+
+	// the next token comes after the deadline, the request fails without waiting for it
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	data := api.ExecuteAPIContext(ctx)
+	assert.NotNil(t, data.URLError)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestExecuteAPIRateLimitSharedHost(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		rw.Write([]byte(authBody))
+	}))
+	defer server.Close()
+	forgetLimiter(t, server.URL)
+
+	limited := Source{Name: "limited", URL: server.URL + "/a", RateLimit: RateLimit{Rate: 0.1}}
+	unlimited := Source{Name: "unlimited", URL: server.URL + "/b"}
+	SetRateLimits([]Source{unlimited, limited})
+	defer SetRateLimits(nil)
+
+	api := API{Client: server.Client(), BaseURL: unlimited.URL, Source: unlimited}
+	assert.Nil(t, api.ExecuteAPI().URLError)
+
+	// the source without a rate limit waits for the bucket of the host
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.NotNil(t, api.ExecuteAPIContext(ctx).URLError)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestSetRateLimits(t *testing.T) {
+	defer SetRateLimits(nil)
+	SetRateLimits([]Source{
+		{URL: "https://limited.example.com/a", Mirrors: []string{"https://mirror.example.com/a"}, RateLimit: RateLimit{Rate: 5}},
+		{URL: "https://limited.example.com/b"},
+		{URL: "https://unlimited.example.com/a"},
+	})
+	limiter := limiterFor("limited.example.com", RateLimit{})
+	assert.Equal(t, rate.Limit(5), limiter.Limit())
+	assert.Equal(t, 1, limiter.Burst())
+	assert.Equal(t, rate.Limit(5), limiterFor("mirror.example.com", RateLimit{}).Limit())
+	assert.Nil(t, limiterFor("unlimited.example.com", RateLimit{}))
+
+	// an unchanged limit keeps its bucket, a changed one gets a new bucket
+	SetRateLimits([]Source{
+		{URL: "https://limited.example.com/a", RateLimit: RateLimit{Rate: 5}},
+		{URL: "https://mirror.example.com/a", RateLimit: RateLimit{Rate: 2, Burst: 3}},
+	})
+	assert.Same(t, limiter, limiterFor("limited.example.com", RateLimit{}))
+	assert.Equal(t, rate.Limit(2), limiterFor("mirror.example.com", RateLimit{}).Limit())
+	assert.Equal(t, 3, limiterFor("mirror.example.com", RateLimit{}).Burst())
+}
+
+func Test_limiterFor(t *testing.T) {
+	defer func() {
+		limiters.Lock()
+		delete(limiters.m, "limited.example.com")
+		limiters.Unlock()
+	}()
+	assert.Nil(t, limiterFor("limited.example.com", RateLimit{}))
+	limiter := limiterFor("limited.example.com", RateLimit{Rate: 5})
+	assert.Equal(t, rate.Limit(5), limiter.Limit())
+	assert.Equal(t, 1, limiter.Burst())
+
+	// the sources of a host share its bucket, whatever their own limit
+	assert.Same(t, limiter, limiterFor("limited.example.com", RateLimit{Rate: 2, Burst: 3}))
+	assert.Same(t, limiter, limiterFor("limited.example.com", RateLimit{}))
+	assert.Equal(t, rate.Limit(5), limiter.Limit())
+	assert.Equal(t, 1, limiter.Burst())
+}
+
+func TestCheckRateLimits(t *testing.T) {
+	assert.Nil(t, checkRateLimits([]Source{
+		{Name: "a", URL: "https://example.com/a", RateLimit: RateLimit{Rate: 2}},
+		{Name: "b", URL: "https://example.com/b"},
+		{Name: "c", URL: "https://example.com/c", RateLimit: RateLimit{Rate: 2, Burst: 1}},
+	}))
+	assert.EqualError(t, checkRateLimits([]Source{
+		{Name: "a", URL: "https://example.com/a", RateLimit: RateLimit{Rate: 2}},
+		{Name: "b", URL: "https://other.example.com/b", Mirrors: []string{"https://example.com/b"}, RateLimit: RateLimit{Rate: 2, Burst: 3}},
+	}), "source b: 'rateLimit' differs from the one of source a on host example.com")
+}
+
+func TestRateLimitCheck(t *testing.T) {
+	assert.Nil(t, RateLimit{}.check())
+	assert.Nil(t, RateLimit{Rate: 0.5, Burst: 5}.check())
+	assert.EqualError(t, RateLimit{Rate: -1}.check(), "rateLimit 'rate' must not be negative")
+	assert.EqualError(t, RateLimit{Burst: -1}.check(), "rateLimit 'burst' must not be negative")
+}
@@ -0,0 +1,44 @@
+package httprequest
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Redacted is written in place of a secret, as in the urls redacted by net/url
+const Redacted = "xxxxx"
+
+// sensitiveNames are parts of the header and query parameter names whose values are redacted
+var sensitiveNames = []string{"auth", "cookie", "key", "password", "secret", "session", "signature", "token"}
+
+// Sensitive reports whether the value of the header or query parameter name is a secret
+func Sensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, part := range sensitiveNames {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// spanURL is the url of a request as recorded in its span: without userinfo,
+// and with the API key of auth and the sensitive query parameters redacted
+func spanURL(rawURL string, auth Auth) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// the url may hold a secret
+		return ""
+	}
+	u.User = nil
+	if u.RawQuery != "" {
+		query := u.Query()
+		for name := range query {
+			if (auth.Type == "apiKey" && name == auth.Query) || Sensitive(name) {
+				query.Set(name, Redacted)
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
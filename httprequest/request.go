@@ -0,0 +1,109 @@
+package httprequest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// bodyData is the data of a source body template
+type bodyData struct {
+	Name string
+	URL  string
+	Now  time.Time
+}
+
+// bodyFuncs are the functions available in a source body template
+var bodyFuncs = template.FuncMap{
+	// env returns the value of an environment variable
+	"env": os.Getenv,
+	// json quotes a value for a JSON document
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// method returns the request method of the source, default GET
+func (s Source) method() string {
+	if s.Method == "" {
+		return http.MethodGet
+	}
+	return strings.ToUpper(s.Method)
+}
+
+func (s Source) bodyTemplate() (*template.Template, error) {
+	return template.New(s.Name).Funcs(bodyFuncs).Option("missingkey=error").Parse(s.Body)
+}
+
+func (s Source) checkRequest() error {
+	switch s.method() {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return errors.New("unsupported 'method': " + s.Method)
+	}
+	for name := range s.Headers {
+		if name == "" || strings.ContainsAny(name, " :\r\n") {
+			return errors.New("invalid header name: " + name)
+		}
+	}
+	if s.Body != "" {
+		if _, err := s.bodyTemplate(); err != nil {
+			return errors.New("invalid 'body': " + err.Error())
+		}
+	}
+	return nil
+}
+
+// newRequest builds the request to the source url with its method, query
+// parameters, headers and body
+func (api *API) newRequest(ctx context.Context) (*http.Request, error) {
+	var body io.Reader
+	if api.Source.Body != "" {
+		tmpl, err := api.Source.bodyTemplate()
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, bodyData{Name: api.Source.Name, URL: api.BaseURL, Now: timeNow()}); err != nil {
+			return nil, errors.New("Error while rendering request body: " + err.Error())
+		}
+		body = &buf
+	}
+	req, err := http.NewRequestWithContext(ctx, api.Source.method(), api.BaseURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(api.Source.Query) > 0 {
+		query := req.URL.Query()
+		for name, value := range api.Source.Query {
+			query.Set(name, value)
+		}
+		req.URL.RawQuery = query.Encode()
+	}
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range api.Source.Headers {
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+	// continue the trace of ctx upstream with the W3C trace context headers
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return req, nil
+}
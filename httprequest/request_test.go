@@ -0,0 +1,92 @@
+package httprequest
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteAPIRequest(t *testing.T) {
+	os.Setenv("TEST_TENANT", "acme")
+	defer os.Unsetenv("TEST_TENANT")
+	defer func() { timeNow = time.Now }()
+	timeNow = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "search.internal", req.Host)
+		assert.Equal(t, "ranker/1.0", req.Header.Get("User-Agent"))
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		assert.Equal(t, "acme", req.Header.Get("X-Tenant-Id"))
+		assert.Equal(t, "1", req.URL.Query().Get("page"))
+		assert.Equal(t, "50", req.URL.Query().Get("size"))
+		assert.JSONEq(t, `{"source": "search", "tenant": "acme", "since": "2024-01-02T03:04:05Z"}`, string(body))
+		rw.Write([]byte(authBody))
+	}))
+	defer server.Close()
+
+	api := API{Client: server.Client(), BaseURL: server.URL + "/?page=1", Source: Source{
+		Name:   "search",
+		Method: "post",
+		Headers: map[string]string{
+			"Host":        "search.internal",
+			"User-Agent":  "ranker/1.0",
+			"X-Tenant-Id": "acme",
+		},
+		Query: map[string]string{"size": "50"},
+		Body:  `{"source": {{json .Name}}, "tenant": {{env "TEST_TENANT" | json}}, "since": {{json .Now}}}`,
+	}}
+	data := api.ExecuteAPI()
+	assert.Nil(t, data.URLError)
+	assert.Len(t, data.UrlData, 1)
+}
+
+func TestNewRequestContentType(t *testing.T) {
+	api := API{BaseURL: "http://example.com", Source: Source{
+		Method:  "PUT",
+		Headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+		Body:    "q=top",
+	}}
+	req, err := api.newRequest(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "application/x-www-form-urlencoded", req.Header.Get("Content-Type"))
+	assert.Equal(t, acceptEncoding, req.Header.Get("Accept-Encoding"))
+	body, _ := ioutil.ReadAll(req.Body)
+	assert.Equal(t, "q=top", string(body))
+}
+
+func TestNewRequestBodyError(t *testing.T) {
+	api := API{BaseURL: "http://example.com", Source: Source{Method: "POST", Body: "{{.Missing}}"}}
+	_, err := api.newRequest(context.Background())
+	assert.NotNil(t, err)
+}
+
+func TestCheckRequest(t *testing.T) {
+	tests := []struct {
+		source Source
+		err    string
+	}{
+		{Source{}, ""},
+		{Source{Method: "post", Body: `{"q": {{json .Name}}}`}, ""},
+		{Source{Method: "DELETE"}, "unsupported 'method': DELETE"},
+		{Source{Headers: map[string]string{"X Tenant": "a"}}, "invalid header name: X Tenant"},
+		{Source{Body: "{{.Name"}, "invalid 'body': "},
+	}
+	for _, tt := range tests {
+		err := tt.source.checkRequest()
+		if tt.err == "" {
+			assert.Nil(t, err)
+		} else {
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		}
+	}
+}
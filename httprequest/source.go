@@ -0,0 +1,157 @@
+package httprequest
+
+import (
+	"assignment/anomaly"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+)
+
+// Source is an upstream url and the configuration used to fetch and read it
+type Source struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Mirrors are the urls tried in order when URL fails
+	Mirrors []string `json:"mirrors"`
+	// Method is the request method, default GET
+	Method string `json:"method"`
+	// Headers and Query are added to the request headers and url query parameters
+	Headers map[string]string `json:"headers"`
+	Query   map[string]string `json:"query"`
+	// Body is a text/template of the request body, sent as JSON unless a Content-Type header is given
+	Body string `json:"body"`
+	// Pattern selects the files read when URL is a file url of a directory, default "*.json"
+	Pattern string `json:"pattern"`
+	// Format is the decoder of the responses, by default selected by Content-Type
+	Format     string       `json:"format"`
+	Mapping    Mapping      `json:"mapping"`
+	CSV        CSVOptions   `json:"csv"`
+	Feed       FeedOptions  `json:"feed"`
+	Validation Validation   `json:"validation"`
+	Auth       Auth         `json:"auth"`
+	TLS        TLSOptions   `json:"tls"`
+	Proxy      ProxyOptions `json:"proxy"`
+	// Transport tunes the connection pool kept for the source
+	Transport TransportOptions `json:"transport"`
+	Hedge     HedgeOptions     `json:"hedge"`
+	// RateLimit limits the requests to each host of the source, shared with the other sources of the host
+	RateLimit RateLimit `json:"rateLimit"`
+	// Anomaly configures the checks of the batches against the recent history, by
+	// default anomaly.DefaultThresholds, the fields not given keeping their default
+	Anomaly *anomaly.Thresholds `json:"anomaly,omitempty"`
+	// MaxBodySize is the maximum size of a response body in bytes, default 10 MiB
+	MaxBodySize int64 `json:"maxBodySize"`
+	// MaxItems is the maximum number of items in a response, default 100000
+	MaxItems int `json:"maxItems"`
+}
+
+// LoadSources reads a JSON array of sources from the file at path
+func LoadSources(path string) ([]Source, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sources []Source
+	if err := json.Unmarshal(body, &sources); err != nil {
+		return nil, errors.New("Error while parsing sources: " + err.Error())
+	}
+	if len(sources) == 0 {
+		return nil, errors.New("no sources in: " + path)
+	}
+
+	names := make(map[string]bool, len(sources))
+	for i := range sources {
+		if sources[i].URL == "" {
+			return nil, errors.New("source 'url' is missing")
+		}
+		if sources[i].Name == "" {
+			sources[i].Name = sources[i].URL
+		}
+		if names[sources[i].Name] {
+			return nil, errors.New("duplicate source name: " + sources[i].Name)
+		}
+		names[sources[i].Name] = true
+		for _, rawURL := range append([]string{sources[i].URL}, sources[i].Mirrors...) {
+			if _, _, err := filePath(rawURL); err != nil {
+				return nil, errors.New("source " + sources[i].Name + ": " + err.Error())
+			}
+		}
+		if err := checkMirrors(sources[i].Mirrors); err != nil {
+			return nil, errors.New("source " + sources[i].Name + ": " + err.Error())
+		}
+		if err := sources[i].checkRequest(); err != nil {
+			return nil, errors.New("source " + sources[i].Name + ": " + err.Error())
+		}
+		if _, err := filepath.Match(sources[i].Pattern, ""); err != nil {
+			return nil, errors.New("source " + sources[i].Name + ": invalid 'pattern': " + err.Error())
+		}
+		if sources[i].Format != "" {
+			if _, err := decoderFor(sources[i], ""); err != nil {
+				return nil, errors.New("source " + sources[i].Name + ": " + err.Error())
+			}
+		}
+		if err := sources[i].CSV.check(); err != nil {
+			return nil, errors.New("source " + sources[i].Name + ": " + err.Error())
+		}
+		if err := sources[i].Mapping.check(); err != nil {
+			return nil, errors.New("source " + sources[i].Name + ": " + err.Error())
+		}
+		if err := sources[i].Validation.check(); err != nil {
+			return nil, errors.New("source " + sources[i].Name + ": " + err.Error())
+		}
+		if err := sources[i].anomalyThresholds().Check(); err != nil {
+			return nil, errors.New("source " + sources[i].Name + ": " + err.Error())
+		}
+		if err := sources[i].Auth.check(); err != nil {
+			return nil, errors.New("source " + sources[i].Name + ": " + err.Error())
+		}
+		if _, err := sources[i].TLS.config(); err != nil {
+			return nil, errors.New("source " + sources[i].Name + ": " + err.Error())
+		}
+		if err := sources[i].Proxy.check(); err != nil {
+			return nil, errors.New("source " + sources[i].Name + ": " + err.Error())
+		}
+		if err := sources[i].Hedge.check(); err != nil {
+			return nil, errors.New("source " + sources[i].Name + ": " + err.Error())
+		}
+		if err := sources[i].RateLimit.check(); err != nil {
+			return nil, errors.New("source " + sources[i].Name + ": " + err.Error())
+		}
+	}
+	if err := checkRateLimits(sources); err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
+
+// anomalyThresholds returns the thresholds of the anomaly checks of the source
+func (s Source) anomalyThresholds() anomaly.Thresholds {
+	if s.Anomaly == nil {
+		return anomaly.DefaultThresholds
+	}
+	return *s.Anomaly
+}
+
+// Duration is a time.Duration read from and written to JSON as a string like "1h30m"
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.New("duration must be a string like \"1h30m\"")
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// MarshalJSON formats the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
@@ -0,0 +1,75 @@
+package httprequest
+
+import (
+	"assignment/anomaly"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeSources(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "sources.json")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSources(t *testing.T) {
+	path := writeSources(t, `[
+		{"name": "wikipedia", "url": "https://example.com/wikipedia.json", "validation": {"action": "repair", "maxViews": 1000}},
+		{"url": "https://example.com/google.json"},
+		{"name": "duckduckgo", "url": "https://example.com/duckduckgo.json", "anomaly": {"quarantine": true, "maxViewsRatio": 5}}
+	]`)
+	quarantine := anomaly.DefaultThresholds
+	quarantine.Quarantine = true
+	quarantine.MaxViewsRatio = 5
+
+	sources, err := LoadSources(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []Source{
+		{
+			Name:       "wikipedia",
+			URL:        "https://example.com/wikipedia.json",
+			Validation: Validation{Action: "repair", MaxViews: 1000},
+		},
+		{
+			Name: "https://example.com/google.json",
+			URL:  "https://example.com/google.json",
+		},
+		{
+			Name:    "duckduckgo",
+			URL:     "https://example.com/duckduckgo.json",
+			Anomaly: &quarantine,
+		},
+	}, sources)
+}
+
+func TestLoadSourcesInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"TestInvalidJSON", `invalid`},
+		{"TestEmpty", `[]`},
+		{"TestURLMissing", `[{"name": "a"}]`},
+		{"TestDuplicateName", `[{"name": "a", "url": "http://a"}, {"name": "a", "url": "http://b"}]`},
+		{"TestInvalidAction", `[{"url": "http://a", "validation": {"action": "abc"}}]`},
+		{"TestInvalidAuth", `[{"url": "http://a", "auth": {"type": "bearer", "token": "abc"}}]`},
+		{"TestAuthSecretMissing", `[{"url": "http://a", "auth": {"type": "bearer"}}]`},
+		{"TestInvalidAnomaly", `[{"url": "http://a", "anomaly": {"minHistory": 0}}]`},
+		{"TestFileURLWithHost", `[{"url": "file://data/google.json"}]`},
+		{"TestRateLimitsOfHostDiffer", `[{"name": "a", "url": "http://a/x", "rateLimit": {"rate": 1}}, {"name": "b", "url": "http://a/y", "rateLimit": {"rate": 2}}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadSources(writeSources(t, tt.content))
+			assert.NotNil(t, err)
+		})
+	}
+
+	_, err := LoadSources(filepath.Join(t.TempDir(), "missing.json"))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,97 @@
+package httprequest
+
+import (
+	"assignment/anomaly"
+	"assignment/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SourceStatus is the state of a source after its last fetch
+type SourceStatus struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Mirror is the mirror url in use while the source url fails
+	Mirror      string     `json:"mirror,omitempty"`
+	LastFetch   time.Time  `json:"lastFetch"`
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+	Items       int        `json:"items"`
+	// Validation counts the items rejected and repaired since the start
+	Validation ValidationReport `json:"validation"`
+	Anomaly    *anomaly.Result  `json:"anomaly,omitempty"`
+	// Pool counts the connections of the source since the start
+	Pool *PoolStats `json:"pool,omitempty"`
+}
+
+var statuses = struct {
+	sync.Mutex
+	m map[string]*SourceStatus
+}{m: make(map[string]*SourceStatus)}
+
+// Statuses returns the status of every source fetched so far, sorted by name
+func Statuses() []SourceStatus {
+	statuses.Lock()
+	defer statuses.Unlock()
+	list := make([]SourceStatus, 0, len(statuses.m))
+	for _, status := range statuses.m {
+		copied := *status
+		copied.Validation = ValidationReport{
+			Rejected: copyCounts(status.Validation.Rejected),
+			Repaired: copyCounts(status.Validation.Repaired),
+		}
+		if mirror, ok := activeURL(status.Name); ok {
+			copied.Mirror = mirror
+		}
+		if pool, ok := poolStats(status.Name); ok {
+			copied.Pool = &pool
+		}
+		list = append(list, copied)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// recordStatus updates the status of source with the result of a fetch
+func recordStatus(source Source, data models.SiteData, report ValidationReport, result anomaly.Result) {
+	statuses.Lock()
+	defer statuses.Unlock()
+	status, ok := statuses.m[source.Name]
+	if !ok {
+		status = &SourceStatus{
+			Name: source.Name,
+			Validation: ValidationReport{
+				Rejected: map[string]int{},
+				Repaired: map[string]int{},
+			},
+		}
+		statuses.m[source.Name] = status
+	}
+
+	now := time.Now().UTC()
+	status.URL = source.URL
+	status.LastFetch = now
+	status.Items = len(data.UrlData)
+	for reason, count := range report.Rejected {
+		status.Validation.Rejected[reason] += count
+	}
+	for reason, count := range report.Repaired {
+		status.Validation.Repaired[reason] += count
+	}
+	if data.URLError != nil {
+		status.LastError = data.URLError.Error()
+		return
+	}
+	status.LastSuccess = &now
+	status.LastError = ""
+	status.Anomaly = &result
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	copied := make(map[string]int, len(counts))
+	for key, count := range counts {
+		copied[key] = count
+	}
+	return copied
+}
@@ -0,0 +1,143 @@
+package httprequest
+
+import (
+	"assignment/models"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// useTracerProvider starts the spans of the package with provider. The global
+// tracer only delegates to the first provider installed, so tracer is replaced.
+func useTracerProvider(t *testing.T, provider trace.TracerProvider) {
+	saved := tracer
+	tracer = provider.Tracer("assignment/httprequest")
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		tracer = saved
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+}
+
+// recordSpans installs a tracer provider keeping the ended spans in memory
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	useTracerProvider(t, sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	return recorder
+}
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestGetContentSpans(t *testing.T) {
+	recorder := recordSpans(t)
+	var parents []string
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		parents = append(parents, req.Header.Get("traceparent"))
+		calls++
+		if calls == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rw.Write([]byte(authBody))
+	}))
+	defer server.Close()
+	defer func(delay time.Duration) { backoff = delay }(backoff)
+	backoff = time.Millisecond
+	source := Source{Name: "trace-test", URL: server.URL}
+	t.Cleanup(func() {
+		statuses.Lock()
+		delete(statuses.m, source.Name)
+		statuses.Unlock()
+	})
+
+	ctx, root := otel.Tracer("test").Start(context.Background(), "root")
+	siteData := make(chan models.SiteData, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	getContent(ctx, source, siteData, &wg)
+	root.End()
+	assert.Nil(t, (<-siteData).URLError)
+
+	spans := map[string][]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = append(spans[span.Name()], span)
+	}
+	traceID := root.SpanContext().TraceID()
+
+	// the source span is a child of the caller, with a backoff event between the attempts
+	fetch := spans["fetch trace-test"][0]
+	assert.Equal(t, root.SpanContext().SpanID(), fetch.Parent().SpanID())
+	assert.Equal(t, int64(1), spanAttribute(fetch, "source.items").AsInt64())
+	assert.False(t, spanAttribute(fetch, "coalesced").AsBool())
+	assert.Len(t, fetch.Events(), 1)
+	assert.Equal(t, "backoff", fetch.Events()[0].Name)
+
+	attempts := spans["attempt"]
+	assert.Len(t, attempts, 2)
+	assert.Equal(t, codes.Error, attempts[0].Status().Code)
+	assert.Equal(t, codes.Unset, attempts[1].Status().Code)
+
+	// each request carries its span upstream
+	requests := spans["HTTP GET"]
+	assert.Len(t, requests, 2)
+	for i, request := range requests {
+		assert.Equal(t, traceID, request.SpanContext().TraceID())
+		assert.Equal(t, attempts[i].SpanContext().SpanID(), request.Parent().SpanID())
+		assert.Equal(t, "00-"+traceID.String()+"-"+request.SpanContext().SpanID().String()+"-01", parents[i])
+	}
+	assert.Equal(t, int64(http.StatusServiceUnavailable), spanAttribute(requests[0], "http.status_code").AsInt64())
+	assert.Equal(t, int64(http.StatusOK), spanAttribute(requests[1], "http.status_code").AsInt64())
+	assert.Equal(t, int64(len(authBody)), spanAttribute(requests[1], "http.response_content_length").AsInt64())
+}
+
+func TestExecuteAPISpanURLRedacted(t *testing.T) {
+	var output bytes.Buffer
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(&output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	useTracerProvider(t, sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Setenv("TEST_AUTH_SECRET", "env-secret")
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(authBody))
+	}))
+	defer server.Close()
+	baseURL := strings.Replace(server.URL, "http://", "http://user:url-password@", 1) + "/?key=url-key&access_token=url-token&page=2"
+	api := API{Client: server.Client(), BaseURL: baseURL, Source: Source{
+		Auth: Auth{Type: "apiKey", Query: "key", Key: Secret{Env: "TEST_AUTH_SECRET"}},
+	}}
+	assert.Nil(t, api.ExecuteAPI().URLError)
+
+	exported := output.String()
+	assert.Contains(t, exported, "http.url")
+	assert.Contains(t, exported, "page=2")
+	for _, secret := range []string{"user", "url-password", "url-key", "url-token", "env-secret"} {
+		assert.NotContains(t, exported, secret)
+	}
+}
@@ -0,0 +1,293 @@
+package httprequest
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// TLSOptions configures the TLS connections to a source
+type TLSOptions struct {
+	// CAFile is a PEM bundle of certificates trusted in addition to the system roots
+	CAFile string `json:"caFile"`
+	// CertFile and KeyFile are the PEM client certificate and key for mutual TLS
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// MinVersion is the minimum TLS version, "1.0" to "1.3", default "1.2"
+	MinVersion string `json:"minVersion"`
+	// ServerName is the name verified in the server certificate, default the url host
+	ServerName string `json:"serverName"`
+}
+
+// ProxyOptions configures the proxy of a source. Without a URL the proxy is
+// taken from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+type ProxyOptions struct {
+	URL string `json:"url"`
+	// NoProxy lists the hosts reached directly, in the NO_PROXY format
+	NoProxy string `json:"noProxy"`
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TransportOptions tunes the connections to a source
+type TransportOptions struct {
+	// DialTimeout limits the time to connect, default 5s
+	DialTimeout Duration `json:"dialTimeout"`
+	// TLSHandshakeTimeout limits the time of the TLS handshake, default 5s
+	TLSHandshakeTimeout Duration `json:"tlsHandshakeTimeout"`
+	// ResponseHeaderTimeout limits the wait for the response headers, by default only the request timeout applies
+	ResponseHeaderTimeout Duration `json:"responseHeaderTimeout"`
+	// IdleConnTimeout is how long idle connections are kept, default 90s
+	IdleConnTimeout Duration `json:"idleConnTimeout"`
+	// MaxIdleConnsPerHost is the number of idle connections kept per host, default 10
+	MaxIdleConnsPerHost int `json:"maxIdleConnsPerHost"`
+	// DisableKeepAlives opens a new connection for every request
+	DisableKeepAlives bool `json:"disableKeepAlives"`
+	// DisableHTTP2 keeps to HTTP/1.1 even when the server supports HTTP/2
+	DisableHTTP2 bool `json:"disableHTTP2"`
+}
+
+const (
+	defaultDialTimeout         = 5 * time.Second
+	defaultTLSHandshakeTimeout = 5 * time.Second
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultMaxIdleConnsPerHost = 10
+)
+
+// PoolStats counts the connections and requests of a source transport
+type PoolStats struct {
+	Requests int64 `json:"requests"`
+	// Reused requests were sent on an already open connection
+	Reused int64 `json:"reused"`
+	Opened int64 `json:"opened"`
+	Open   int64 `json:"open"`
+}
+
+// poolCounters are the live counters behind PoolStats
+type poolCounters struct {
+	requests, reused, opened, closed int64
+}
+
+func (c *poolCounters) stats() PoolStats {
+	opened := atomic.LoadInt64(&c.opened)
+	return PoolStats{
+		Requests: atomic.LoadInt64(&c.requests),
+		Reused:   atomic.LoadInt64(&c.reused),
+		Opened:   opened,
+		Open:     opened - atomic.LoadInt64(&c.closed),
+	}
+}
+
+// countedConn decrements the open connections when closed
+type countedConn struct {
+	net.Conn
+	counters *poolCounters
+	once     sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() { atomic.AddInt64(&c.counters.closed, 1) })
+	return c.Conn.Close()
+}
+
+// countingTransport counts the requests sent and the connections they reused
+type countingTransport struct {
+	*http.Transport
+	counters *poolCounters
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&t.counters.requests, 1)
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				atomic.AddInt64(&t.counters.reused, 1)
+			}
+		},
+	}
+	return t.Transport.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+// sourceClient is the client of a source and the options it was built with
+type sourceClient struct {
+	tls       TLSOptions
+	proxy     ProxyOptions
+	options   TransportOptions
+	timeout   time.Duration
+	client    *http.Client
+	transport *countingTransport
+}
+
+// clients keeps the clients of the sources by name, so their connections
+// are reused across fetches
+var clients = struct {
+	sync.Mutex
+	m map[string]*sourceClient
+}{m: make(map[string]*sourceClient)}
+
+// clientFor returns the long-lived client of the source, rebuilt when its options change
+func clientFor(source Source) (*http.Client, error) {
+	clients.Lock()
+	defer clients.Unlock()
+	cached, ok := clients.m[source.Name]
+	if ok && cached.tls == source.TLS && cached.proxy == source.Proxy && cached.options == source.Transport && cached.timeout == Timeout {
+		return cached.client, nil
+	}
+	counters := &poolCounters{}
+	if ok {
+		// keep counting across rebuilds
+		counters = cached.transport.counters
+	}
+	transport, err := newTransport(source, counters)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		cached.transport.CloseIdleConnections()
+	}
+	clients.m[source.Name] = &sourceClient{
+		tls:       source.TLS,
+		proxy:     source.Proxy,
+		options:   source.Transport,
+		timeout:   Timeout,
+		client:    &http.Client{Timeout: Timeout, Transport: transport},
+		transport: transport,
+	}
+	return clients.m[source.Name].client, nil
+}
+
+// poolStats returns the connection pool stats of the source client
+func poolStats(name string) (PoolStats, bool) {
+	clients.Lock()
+	defer clients.Unlock()
+	cached, ok := clients.m[name]
+	if !ok {
+		return PoolStats{}, false
+	}
+	return cached.transport.counters.stats(), true
+}
+
+func newTransport(source Source, counters *poolCounters) (*countingTransport, error) {
+	options := source.Transport
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig, err := source.TLS.config()
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	dialer := &net.Dialer{Timeout: defaultDialTimeout, KeepAlive: 30 * time.Second}
+	if options.DialTimeout > 0 {
+		dialer.Timeout = time.Duration(options.DialTimeout)
+	}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		atomic.AddInt64(&counters.opened, 1)
+		return &countedConn{Conn: conn, counters: counters}, nil
+	}
+	transport.TLSHandshakeTimeout = defaultTLSHandshakeTimeout
+	if options.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = time.Duration(options.TLSHandshakeTimeout)
+	}
+	transport.ResponseHeaderTimeout = time.Duration(options.ResponseHeaderTimeout)
+	transport.IdleConnTimeout = defaultIdleConnTimeout
+	if options.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = time.Duration(options.IdleConnTimeout)
+	}
+	transport.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	if options.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = options.MaxIdleConnsPerHost
+	}
+	transport.DisableKeepAlives = options.DisableKeepAlives
+	if options.DisableHTTP2 {
+		transport.ForceAttemptHTTP2 = false
+		// a non-nil empty map disables HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	if source.Proxy != (ProxyOptions{}) {
+		config := httpproxy.FromEnvironment()
+		if source.Proxy.URL != "" {
+			config.HTTPProxy = source.Proxy.URL
+			config.HTTPSProxy = source.Proxy.URL
+		}
+		if source.Proxy.NoProxy != "" {
+			config.NoProxy = source.Proxy.NoProxy
+		}
+		proxy := config.ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxy(req.URL)
+		}
+	}
+	return &countingTransport{Transport: transport, counters: counters}, nil
+}
+
+func (o TLSOptions) config() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: o.ServerName}
+	if o.MinVersion != "" {
+		version, ok := tlsVersions[o.MinVersion]
+		if !ok {
+			return nil, errors.New("unsupported tls 'minVersion': " + o.MinVersion)
+		}
+		config.MinVersion = version
+	}
+	if o.CAFile != "" {
+		pem, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, errors.New("Error while reading tls 'caFile': " + err.Error())
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificate in tls 'caFile': " + o.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if o.CertFile != "" || o.KeyFile != "" {
+		if o.CertFile == "" || o.KeyFile == "" {
+			return nil, errors.New("tls 'certFile' and 'keyFile' must be given together")
+		}
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, errors.New("Error while loading tls client certificate: " + err.Error())
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func (o ProxyOptions) check() error {
+	if o.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(o.URL)
+	if err != nil || u.Host == "" {
+		return errors.New("invalid proxy 'url'")
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+		return nil
+	}
+	return errors.New("unsupported proxy scheme: " + u.Scheme)
+}
@@ -0,0 +1,229 @@
+package httprequest
+
+import (
+	"assignment/models"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writePEM writes a PEM block to a file in dir and returns its path
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	path := filepath.Join(dir, name)
+	writeFile(t, path, string(pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})))
+	return path
+}
+
+// clientCertificate creates a self-signed client certificate and returns it with the paths of its PEM files
+func clientCertificate(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ranker"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, writePEM(t, dir, "client.pem", "CERTIFICATE", der), writePEM(t, dir, "client-key.pem", "EC PRIVATE KEY", keyDER)
+}
+
+func TestGetContentMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	clientCert, certFile, keyFile := clientCertificate(t, dir)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "ranker", req.TLS.PeerCertificates[0].Subject.CommonName)
+		assert.Equal(t, uint16(tls.VersionTLS13), req.TLS.Version)
+		rw.Write([]byte(authBody))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+	caFile := writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	source := Source{Name: "mtls", URL: server.URL, TLS: TLSOptions{
+		CAFile:     caFile,
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		MinVersion: "1.3",
+		ServerName: "example.com",
+	}}
+	ch := make(chan models.SiteData, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	getContent(context.Background(), source, ch, &wg)
+	data := <-ch
+	assert.Nil(t, data.URLError)
+	assert.Len(t, data.UrlData, 1)
+
+	// the client is kept for the next fetches
+	first, _ := clientFor(source)
+	second, _ := clientFor(source)
+	assert.Same(t, first, second)
+
+	// and rebuilt when its options change
+	source.TLS.MinVersion = "1.2"
+	third, _ := clientFor(source)
+	assert.NotSame(t, first, third)
+}
+
+func TestGetContentReusesConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(authBody))
+	}))
+	defer server.Close()
+
+	source := Source{Name: "pooled", URL: server.URL}
+	t.Cleanup(func() {
+		clients.Lock()
+		delete(clients.m, source.Name)
+		clients.Unlock()
+	})
+	for i := 0; i < 3; i++ {
+		ch := make(chan models.SiteData, 1)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		getContent(context.Background(), source, ch, &wg)
+		assert.Nil(t, (<-ch).URLError)
+	}
+
+	stats, ok := poolStats("pooled")
+	assert.True(t, ok)
+	assert.Equal(t, PoolStats{Requests: 3, Reused: 2, Opened: 1, Open: 1}, stats)
+	for _, status := range Statuses() {
+		if status.Name == "pooled" {
+			assert.Equal(t, &stats, status.Pool)
+		}
+	}
+
+	client, _ := clientFor(source)
+	client.CloseIdleConnections()
+	stats, _ = poolStats("pooled")
+	assert.Equal(t, int64(0), stats.Open)
+}
+
+func TestNewTransportOptions(t *testing.T) {
+	transport, err := newTransport(Source{Transport: TransportOptions{
+		TLSHandshakeTimeout:   Duration(time.Second),
+		ResponseHeaderTimeout: Duration(3 * time.Second),
+		IdleConnTimeout:       Duration(time.Minute),
+		MaxIdleConnsPerHost:   2,
+		DisableKeepAlives:     true,
+		DisableHTTP2:          true,
+	}}, &poolCounters{})
+	assert.Nil(t, err)
+	assert.Equal(t, time.Second, transport.TLSHandshakeTimeout)
+	assert.Equal(t, 3*time.Second, transport.ResponseHeaderTimeout)
+	assert.Equal(t, time.Minute, transport.IdleConnTimeout)
+	assert.Equal(t, 2, transport.MaxIdleConnsPerHost)
+	assert.True(t, transport.DisableKeepAlives)
+	assert.False(t, transport.ForceAttemptHTTP2)
+	assert.NotNil(t, transport.TLSNextProto)
+
+	transport, err = newTransport(Source{}, &poolCounters{})
+	assert.Nil(t, err)
+	assert.Equal(t, defaultMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+	assert.True(t, transport.ForceAttemptHTTP2)
+}
+
+func TestTransportProxy(t *testing.T) {
+	transport, err := newTransport(Source{Proxy: ProxyOptions{
+		URL:     "http://proxy.internal:3128",
+		NoProxy: "internal.example.com,.corp",
+	}}, &poolCounters{})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		url   string
+		proxy string
+	}{
+		{"https://raw.githubusercontent.com/a.json", "http://proxy.internal:3128"},
+		{"http://example.com/a.json", "http://proxy.internal:3128"},
+		{"https://internal.example.com/a.json", ""},
+		{"https://feeds.corp/a.json", ""},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+		proxy, err := transport.Proxy(req)
+		assert.Nil(t, err)
+		if tt.proxy == "" {
+			assert.Nil(t, proxy, tt.url)
+		} else {
+			assert.Equal(t, tt.proxy, proxy.String(), tt.url)
+		}
+	}
+}
+
+func TestTransportProxyFromEnvironment(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://env-proxy:3128")
+	t.Setenv("NO_PROXY", "")
+	transport, err := newTransport(Source{Proxy: ProxyOptions{NoProxy: "internal.example.com"}}, &poolCounters{})
+	assert.Nil(t, err)
+
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/a.json", nil)
+	proxy, _ := transport.Proxy(req)
+	assert.Equal(t, "http://env-proxy:3128", proxy.String())
+	req, _ = http.NewRequest(http.MethodGet, "https://internal.example.com/a.json", nil)
+	proxy, _ = transport.Proxy(req)
+	assert.Nil(t, proxy)
+}
+
+func TestTLSOptionsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.pem")
+	writeFile(t, notPEM, "not a certificate")
+
+	tests := []struct {
+		options TLSOptions
+		err     string
+	}{
+		{TLSOptions{MinVersion: "1.4"}, "unsupported tls 'minVersion': 1.4"},
+		{TLSOptions{CAFile: notPEM}, "no certificate in tls 'caFile': " + notPEM},
+		{TLSOptions{CertFile: "client.pem"}, "tls 'certFile' and 'keyFile' must be given together"},
+	}
+	for _, tt := range tests {
+		_, err := tt.options.config()
+		assert.EqualError(t, err, tt.err)
+	}
+}
+
+func TestProxyOptionsCheck(t *testing.T) {
+	assert.Nil(t, ProxyOptions{}.check())
+	assert.Nil(t, ProxyOptions{URL: "socks5://proxy:1080"}.check())
+	assert.EqualError(t, ProxyOptions{URL: "proxy"}.check(), "invalid proxy 'url'")
+	assert.EqualError(t, ProxyOptions{URL: "ftp://proxy"}.check(), "unsupported proxy scheme: ftp")
+}
@@ -0,0 +1,98 @@
+package httprequest
+
+import (
+	"assignment/models"
+	"errors"
+	"math"
+)
+
+// Reasons for which items are rejected or repaired
+const (
+	EmptyUrl                 = "emptyUrl"
+	DuplicateUrl             = "duplicateUrl"
+	NegativeViews            = "negativeViews"
+	TooManyViews             = "tooManyViews"
+	InvalidRelevanceScore    = "invalidRelevanceScore"
+	RelevanceScoreOutOfRange = "relevanceScoreOutOfRange"
+)
+
+// Validation are the rules the items of a source must follow
+type Validation struct {
+	// Action is "drop" (the default) to reject invalid items or
+	// "repair" to fix them when possible by clamping their values
+	Action string `json:"action"`
+	// MaxViews is the maximum number of views of an item, 0 means no maximum
+	MaxViews int `json:"maxViews"`
+	// MaxRelevanceScore is the maximum relevanceScore of an item, 0 means 1
+	MaxRelevanceScore float64 `json:"maxRelevanceScore"`
+	// AllowDuplicates keeps items whose url was already seen in the batch
+	AllowDuplicates bool `json:"allowDuplicates"`
+}
+
+// ValidationReport counts the items rejected and repaired per reason
+type ValidationReport struct {
+	Rejected map[string]int `json:"rejected,omitempty"`
+	Repaired map[string]int `json:"repaired,omitempty"`
+}
+
+func (v Validation) check() error {
+	if v.Action != "" && v.Action != "drop" && v.Action != "repair" {
+		return errors.New("validation 'action' must be 'drop' or 'repair'")
+	}
+	if v.MaxViews < 0 || v.MaxRelevanceScore < 0 {
+		return errors.New("validation maximums must not be negative")
+	}
+	return nil
+}
+
+// validate drops or repairs the items of data breaking the rules.
+// A dropped item is counted once, under the first rule it breaks.
+func validate(rules Validation, data *models.SiteData) ValidationReport {
+	report := ValidationReport{Rejected: map[string]int{}, Repaired: map[string]int{}}
+	maxRelevance := rules.MaxRelevanceScore
+	if maxRelevance == 0 {
+		maxRelevance = 1
+	}
+
+	seen := make(map[string]bool, len(data.UrlData))
+	valid := data.UrlData[:0]
+	for _, item := range data.UrlData {
+		if item.Url == "" {
+			report.Rejected[EmptyUrl]++
+			continue
+		}
+		if seen[item.Url] && !rules.AllowDuplicates {
+			report.Rejected[DuplicateUrl]++
+			continue
+		}
+
+		var reasons []string
+		if item.Views < 0 {
+			reasons = append(reasons, NegativeViews)
+			item.Views = 0
+		}
+		if rules.MaxViews > 0 && item.Views > rules.MaxViews {
+			reasons = append(reasons, TooManyViews)
+			item.Views = rules.MaxViews
+		}
+		if math.IsNaN(item.RelevanceScore) || math.IsInf(item.RelevanceScore, 0) {
+			reasons = append(reasons, InvalidRelevanceScore)
+			item.RelevanceScore = 0
+		} else if item.RelevanceScore < 0 || item.RelevanceScore > maxRelevance {
+			reasons = append(reasons, RelevanceScoreOutOfRange)
+			item.RelevanceScore = math.Max(0, math.Min(item.RelevanceScore, maxRelevance))
+		}
+
+		if len(reasons) > 0 && rules.Action != "repair" {
+			report.Rejected[reasons[0]]++
+			continue
+		}
+		for _, reason := range reasons {
+			report.Repaired[reason]++
+		}
+		seen[item.Url] = true
+		valid = append(valid, item)
+	}
+	data.UrlData = valid
+	return report
+}
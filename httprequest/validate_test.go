@@ -0,0 +1,76 @@
+package httprequest
+
+import (
+	"assignment/models"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func invalidData() models.SiteData {
+	return models.SiteData{
+		UrlData: []models.UrlData{
+			{Url: "www.wikipedia.com/abc1", Views: 11000, RelevanceScore: 0.1},
+			{Url: "", Views: 12000, RelevanceScore: 0.2},
+			{Url: "www.wikipedia.com/abc1", Views: 13000, RelevanceScore: 0.3},
+			{Url: "www.wikipedia.com/abc2", Views: -5, RelevanceScore: 0.4},
+			{Url: "www.wikipedia.com/abc3", Views: 500000, RelevanceScore: 0.5},
+			{Url: "www.wikipedia.com/abc4", Views: 14000, RelevanceScore: math.NaN()},
+			{Url: "www.wikipedia.com/abc5", Views: 15000, RelevanceScore: 1.5},
+			{Url: "www.wikipedia.com/abc6", Views: -1, RelevanceScore: -0.5},
+		},
+	}
+}
+
+func Test_validateDrop(t *testing.T) {
+	data := invalidData()
+	report := validate(Validation{MaxViews: 100000}, &data)
+
+	assert.Equal(t, []models.UrlData{
+		{Url: "www.wikipedia.com/abc1", Views: 11000, RelevanceScore: 0.1},
+	}, data.UrlData)
+	assert.Equal(t, map[string]int{
+		EmptyUrl:                 1,
+		DuplicateUrl:             1,
+		NegativeViews:            2,
+		TooManyViews:             1,
+		InvalidRelevanceScore:    1,
+		RelevanceScoreOutOfRange: 1,
+	}, report.Rejected)
+	assert.Equal(t, map[string]int{}, report.Repaired)
+}
+
+func Test_validateRepair(t *testing.T) {
+	data := invalidData()
+	report := validate(Validation{Action: "repair", MaxViews: 100000, AllowDuplicates: true}, &data)
+
+	assert.Equal(t, []models.UrlData{
+		{Url: "www.wikipedia.com/abc1", Views: 11000, RelevanceScore: 0.1},
+		{Url: "www.wikipedia.com/abc1", Views: 13000, RelevanceScore: 0.3},
+		{Url: "www.wikipedia.com/abc2", Views: 0, RelevanceScore: 0.4},
+		{Url: "www.wikipedia.com/abc3", Views: 100000, RelevanceScore: 0.5},
+		{Url: "www.wikipedia.com/abc4", Views: 14000, RelevanceScore: 0},
+		{Url: "www.wikipedia.com/abc5", Views: 15000, RelevanceScore: 1},
+		{Url: "www.wikipedia.com/abc6", Views: 0, RelevanceScore: 0},
+	}, data.UrlData)
+	assert.Equal(t, map[string]int{EmptyUrl: 1}, report.Rejected)
+	assert.Equal(t, map[string]int{
+		NegativeViews:            2,
+		TooManyViews:             1,
+		InvalidRelevanceScore:    1,
+		RelevanceScoreOutOfRange: 2,
+	}, report.Repaired)
+}
+
+func Test_validateMaxRelevanceScore(t *testing.T) {
+	data := models.SiteData{
+		UrlData: []models.UrlData{
+			{Url: "www.wikipedia.com/abc1", Views: 11000, RelevanceScore: 50},
+			{Url: "www.wikipedia.com/abc2", Views: 11000, RelevanceScore: 150},
+		},
+	}
+	report := validate(Validation{MaxRelevanceScore: 100}, &data)
+	assert.Equal(t, 1, len(data.UrlData))
+	assert.Equal(t, map[string]int{RelevanceScoreOutOfRange: 1}, report.Rejected)
+}
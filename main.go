@@ -1,17 +1,125 @@
 package main
 
 import (
+	"assignment/config"
+	"assignment/httprequest"
+	"assignment/metrics"
 	"assignment/server"
+	"assignment/store"
+	"assignment/tracing"
+	"context"
+	"errors"
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
+	cfg, printConfig, err := config.Load(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Replace the default sources with the ones of the sources file
+	if cfg.SourcesFile != "" {
+		sources, err := httprequest.LoadSources(cfg.SourcesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		server.SetSources(sources)
+	}
+	if err := cfg.CheckSources(server.SourceList()); err != nil {
+		log.Fatal(err)
+	}
+	if printConfig {
+		if err := config.Print(os.Stdout, cfg, server.SourceList()); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	log.Println("Starting HTTP server")
+
+	server.MaxLimit = cfg.MaxLimit
+	server.SetWebhookLimits(cfg.MaxWebhooks, cfg.WebhookHosts)
+	server.MinReadySources = cfg.MinReadySources
+	server.RequestTimeout = time.Duration(cfg.RequestTimeout)
+	httprequest.Timeout = time.Duration(cfg.UpstreamTimeout)
+	httprequest.Retries = cfg.UpstreamRetries
+
+	// Keep the snapshots for 'asOf' queries
+	snapshots, err := store.Open(cfg.SnapshotsDir, time.Duration(cfg.SnapshotMaxAge), cfg.SnapshotMaxCount)
+	if err != nil {
+		log.Fatal(err)
+	}
+	server.Snapshots = snapshots
+
+	// Trace the requests, the fetches of the sources and their attempts
+	tracingOptions := cfg.Tracing()
+	tracingOptions.Output = os.Stdout
+	stopTracing, err := tracing.Setup(context.Background(), tracingOptions)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	http.HandleFunc("/getData", server.GetData)
+	http.HandleFunc("/getData/diff", server.GetDataDiff)
+	http.HandleFunc("/webhooks", server.Webhooks)
+	http.HandleFunc("/sources", server.Sources)
+	http.HandleFunc("/healthz", server.Healthz)
+	http.HandleFunc("/readyz", server.Readyz)
+	http.Handle("/metrics", metrics.Handler())
+
+	// Stop on SIGTERM, as sent by Kubernetes, or on interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// Refresh the data in the background to notify webhooks of ranking changes
+	refresher := make(chan struct{})
+	go func() {
+		server.StartRefresher(ctx, time.Duration(cfg.RefreshInterval))
+		close(refresher)
+	}()
 
 	// Start HTTP server
-	if err := http.ListenAndServe(":8000", nil); err != nil {
-		log.Fatal(err)
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		ReadHeaderTimeout: time.Duration(cfg.ReadHeaderTimeout),
+		ReadTimeout:       time.Duration(cfg.ReadTimeout),
+		WriteTimeout:      time.Duration(cfg.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.IdleTimeout),
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down HTTP server")
+
+	// Drain the in-flight requests and deliveries before the pod is killed
+	drain, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout))
+	defer cancel()
+	if err := srv.Shutdown(drain); err != nil {
+		log.Println("Error while shutting down HTTP server: ", err)
+	}
+	select {
+	case <-refresher:
+	case <-drain.Done():
+		log.Println("Refresher did not stop in time")
+	}
+	if err := server.StopWebhooks(drain); err != nil {
+		log.Println("Error while stopping webhook deliveries: ", err)
+	}
+	if err := stopTracing(drain); err != nil {
+		log.Println("Error while flushing spans: ", err)
 	}
+	log.Println("HTTP server stopped")
 }
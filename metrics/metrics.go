@@ -0,0 +1,110 @@
+// Package metrics defines the Prometheus metrics of the server. They are
+// registered on the default registry, along with the Go runtime and process
+// collectors, and served in the text format by Handler.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Results of a cache lookup
+const (
+	Hit  = "hit"
+	Miss = "miss"
+)
+
+var (
+	// Requests counts the getData requests by status code and sortKey
+	Requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "getdata_requests_total",
+		Help: "Number of getData requests by status code and sortKey.",
+	}, []string{"status", "sortKey"})
+
+	// RequestDuration is the latency of the getData requests by status code and sortKey
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "getdata_request_duration_seconds",
+		Help:    "Latency of the getData requests by status code and sortKey.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"status", "sortKey"})
+
+	// RequestsInFlight is the number of getData requests being served
+	RequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "getdata_requests_in_flight",
+		Help: "Number of getData requests being served.",
+	})
+
+	// FetchDuration is the latency of the fetches of a source, retries included
+	FetchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "upstream_fetch_duration_seconds",
+		Help:    "Latency of the fetches of a source, retries included.",
+		Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"source"})
+
+	// FetchesInFlight is the number of fetches running, shared by the requests asking for their source
+	FetchesInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "upstream_fetches_in_flight",
+		Help: "Number of source fetches running.",
+	})
+
+	// Attempts counts the requests sent to a source, mirrors and hedges included
+	Attempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "upstream_attempts_total",
+		Help: "Number of requests sent to a source, mirrors and hedges included.",
+	}, []string{"source"})
+
+	// Errors counts the failed requests to a source by class of error
+	Errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "upstream_errors_total",
+		Help: "Number of failed requests to a source by class of error.",
+	}, []string{"source", "class"})
+
+	// Retries counts the retries of the fetches of a source
+	Retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "upstream_retries_total",
+		Help: "Number of retries of the fetches of a source.",
+	}, []string{"source"})
+
+	// Items is the number of items of the last successful fetch of a source
+	Items = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "upstream_items",
+		Help: "Number of items of the last successful fetch of a source.",
+	}, []string{"source"})
+
+	// CacheLookups counts the lookups of the caches by result, hit or miss
+	CacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_lookups_total",
+		Help: "Number of lookups of the file, token and in-flight fetch caches by result.",
+	}, []string{"cache", "result"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		Requests,
+		RequestDuration,
+		RequestsInFlight,
+		FetchDuration,
+		FetchesInFlight,
+		Attempts,
+		Errors,
+		Retries,
+		Items,
+		CacheLookups,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// CacheLookup counts a lookup of cache, a hit when found
+func CacheLookup(cache string, found bool) {
+	result := Miss
+	if found {
+		result = Hit
+	}
+	CacheLookups.WithLabelValues(cache, result).Inc()
+}
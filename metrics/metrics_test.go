@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheLookup(t *testing.T) {
+	hits := testutil.ToFloat64(CacheLookups.WithLabelValues("test", Hit))
+	misses := testutil.ToFloat64(CacheLookups.WithLabelValues("test", Miss))
+	CacheLookup("test", true)
+	CacheLookup("test", true)
+	CacheLookup("test", false)
+	assert.Equal(t, hits+2, testutil.ToFloat64(CacheLookups.WithLabelValues("test", Hit)))
+	assert.Equal(t, misses+1, testutil.ToFloat64(CacheLookups.WithLabelValues("test", Miss)))
+}
+
+func TestHandler(t *testing.T) {
+	Requests.WithLabelValues("200", "views").Inc()
+	Attempts.WithLabelValues("test").Inc()
+
+	rr := httptest.NewRecorder()
+	Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, 200, rr.Code)
+	assert.Contains(t, rr.Header().Get("Content-Type"), "text/plain")
+	body := rr.Body.String()
+	assert.Contains(t, body, `getdata_requests_total{sortKey="views",status="200"}`)
+	assert.Contains(t, body, `upstream_attempts_total{source="test"}`)
+	assert.Contains(t, body, "getdata_requests_in_flight 0")
+	assert.Contains(t, body, "go_goroutines ")
+}
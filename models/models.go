@@ -1,17 +1,74 @@
 package models
 
+import (
+	"sort"
+	"time"
+)
+
 type SiteData struct {
 	UrlData  []UrlData `json:"data"`
 	URLError error
 }
 
 type SiteDataResponse struct {
-	UrlData []UrlData `json:"data"`
-	Count   int       `json:"count"`
+	UrlData      []UrlData  `json:"data"`
+	Count        int        `json:"count"`
+	SnapshotTime *time.Time `json:"snapshotTime,omitempty"`
 }
 
 type UrlData struct {
-	Url            string  `json:"url"`
-	Views          int     `json:"views"`
-	RelevanceScore float64 `json:"relevanceScore"`
+	Url              string  `json:"url"`
+	Views            int     `json:"views"`
+	RelevanceScore   float64 `json:"relevanceScore"`
+	ViewVelocity     float64 `json:"viewVelocity,omitempty"`
+	ViewAcceleration float64 `json:"viewAcceleration,omitempty"`
+	RelevanceDrift   float64 `json:"relevanceDrift,omitempty"`
+}
+
+type Snapshot struct {
+	Time    time.Time `json:"time"`
+	UrlData []UrlData `json:"data"`
+}
+
+type DiffResponse struct {
+	From    time.Time    `json:"from"`
+	To      time.Time    `json:"to"`
+	SortKey string       `json:"sortKey"`
+	Added   []RankedData `json:"added"`
+	Removed []RankedData `json:"removed"`
+	Changed []UrlDiff    `json:"changed"`
+}
+
+type RankedData struct {
+	UrlData
+	Rank int `json:"rank"`
+}
+
+type UrlDiff struct {
+	Url                 string  `json:"url"`
+	FromRank            int     `json:"fromRank"`
+	ToRank              int     `json:"toRank"`
+	RankChange          int     `json:"rankChange"`
+	ViewsDelta          int     `json:"viewsDelta"`
+	RelevanceScoreDelta float64 `json:"relevanceScoreDelta"`
+}
+
+// SortByKey orders data by the value of key in ascending order, the ranking
+// served by getData. Items with equal values keep their order.
+func SortByKey(data []UrlData, key string) {
+	sort.SliceStable(data, func(i, j int) bool {
+		switch key {
+		case "relevanceScore":
+			return data[i].RelevanceScore < data[j].RelevanceScore
+		case "views":
+			return data[i].Views < data[j].Views
+		case "viewVelocity":
+			return data[i].ViewVelocity < data[j].ViewVelocity
+		case "viewAcceleration":
+			return data[i].ViewAcceleration < data[j].ViewAcceleration
+		case "relevanceDrift":
+			return data[i].RelevanceDrift < data[j].RelevanceDrift
+		}
+		return false
+	})
 }
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"assignment/models"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// GetDataDiff handles getData/diff request: it compares the snapshots nearest at or
+// before 'from' and 'to' and writes the added, removed and changed urls to ResponseWriter.
+// Ranks are the 1-based positions in the getData ordering for 'sortKey'.
+func GetDataDiff(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	key, err := validateSortKey(req, sortKeys)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+
+	var times [2]time.Time
+	for i, name := range []string{"from", "to"} {
+		t, ok, err := parseTime(req, name)
+		if err == nil && !ok {
+			err = errors.New("url parameter '" + name + "' is missing")
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+		times[i] = t
+	}
+
+	var snapshots [2]models.SiteDataResponse
+	for i, t := range times {
+		data, errCode, err := snapshotData(t)
+		if err != nil {
+			http.Error(w, err.Error(), errCode)
+			log.Println(err)
+			return
+		}
+		snapshots[i] = data
+	}
+
+	writeJSON(w, http.StatusOK, diffData(snapshots[0], snapshots[1], key))
+}
+
+// diffData compares the rankings of two snapshots for key
+func diffData(from, to models.SiteDataResponse, key string) models.DiffResponse {
+	diff := models.DiffResponse{
+		From:    *from.SnapshotTime,
+		To:      *to.SnapshotTime,
+		SortKey: key,
+		Added:   []models.RankedData{},
+		Removed: []models.RankedData{},
+		Changed: []models.UrlDiff{},
+	}
+
+	fromRanks := rank(from, key)
+	toRanks := rank(to, key)
+	for i, data := range to.UrlData {
+		toRank := toRanks[data.Url]
+		if toRank.Rank != i+1 {
+			continue // duplicate url, only the best ranked one counts
+		}
+		fromRank, ok := fromRanks[data.Url]
+		if !ok {
+			diff.Added = append(diff.Added, models.RankedData{UrlData: data, Rank: toRank.Rank})
+			continue
+		}
+		change := models.UrlDiff{
+			Url:                 data.Url,
+			FromRank:            fromRank.Rank,
+			ToRank:              toRank.Rank,
+			RankChange:          fromRank.Rank - toRank.Rank,
+			ViewsDelta:          data.Views - fromRank.Views,
+			RelevanceScoreDelta: data.RelevanceScore - fromRank.RelevanceScore,
+		}
+		if change.RankChange != 0 || change.ViewsDelta != 0 || change.RelevanceScoreDelta != 0 {
+			diff.Changed = append(diff.Changed, change)
+		}
+	}
+	for i, data := range from.UrlData {
+		if fromRanks[data.Url].Rank != i+1 {
+			continue
+		}
+		if _, ok := toRanks[data.Url]; !ok {
+			diff.Removed = append(diff.Removed, fromRanks[data.Url])
+		}
+	}
+	return diff
+}
+
+// rank sorts data by key and returns the ranked data by url
+func rank(data models.SiteDataResponse, key string) map[string]models.RankedData {
+	sortKey(data, key)
+	ranks := make(map[string]models.RankedData, len(data.UrlData))
+	for i, urlData := range data.UrlData {
+		if _, ok := ranks[urlData.Url]; !ok {
+			ranks[urlData.Url] = models.RankedData{UrlData: urlData, Rank: i + 1}
+		}
+	}
+	return ranks
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"assignment/models"
+	"assignment/store"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDataDiff(t *testing.T) {
+	var err error
+	Snapshots, err = store.Open(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { Snapshots = nil }()
+
+	from := time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	snapshots := []models.Snapshot{
+		{
+			Time: from,
+			UrlData: []models.UrlData{
+				{Url: "www.example.com/abc1", Views: 1000, RelevanceScore: 0.4},
+				{Url: "www.example.com/abc2", Views: 2000, RelevanceScore: 0.5},
+				{Url: "www.example.com/abc3", Views: 3000, RelevanceScore: 0.6},
+			},
+		},
+		{
+			Time: to,
+			UrlData: []models.UrlData{
+				{Url: "www.example.com/abc1", Views: 2500, RelevanceScore: 0.45},
+				{Url: "www.example.com/abc3", Views: 3000, RelevanceScore: 0.6},
+				{Url: "www.example.com/abc4", Views: 500, RelevanceScore: 0.1},
+			},
+		},
+	}
+	for _, snapshot := range snapshots {
+		if err := Snapshots.Save(snapshot); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req, err := http.NewRequest("GET", "/getData/diff?sortKey=views&from=2022-05-01T06:00:00Z&to=2022-05-02T06:00:00Z", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetDataDiff).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("TestGetDataDiff: handler returned wrong status code: got %v want %v",
+			status, http.StatusOK)
+	}
+
+	var diff models.DiffResponse
+	err = json.Unmarshal(rr.Body.Bytes(), &diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, from.Equal(diff.From))
+	assert.True(t, to.Equal(diff.To))
+	assert.Equal(t, "views", diff.SortKey)
+	assert.Equal(t, []models.RankedData{
+		{UrlData: models.UrlData{Url: "www.example.com/abc4", Views: 500, RelevanceScore: 0.1}, Rank: 1},
+	}, diff.Added)
+	assert.Equal(t, []models.RankedData{
+		{UrlData: models.UrlData{Url: "www.example.com/abc2", Views: 2000, RelevanceScore: 0.5}, Rank: 2},
+	}, diff.Removed)
+	assert.Equal(t, 1, len(diff.Changed))
+	assert.Equal(t, "www.example.com/abc1", diff.Changed[0].Url)
+	assert.Equal(t, 1, diff.Changed[0].FromRank)
+	assert.Equal(t, 2, diff.Changed[0].ToRank)
+	assert.Equal(t, -1, diff.Changed[0].RankChange)
+	assert.Equal(t, 1500, diff.Changed[0].ViewsDelta)
+	assert.InDelta(t, 0.05, diff.Changed[0].RelevanceScoreDelta, 1e-9)
+}
+
+func TestGetDataDiffInvalidRequest(t *testing.T) {
+	var err error
+	Snapshots, err = store.Open(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { Snapshots = nil }()
+
+	tests := []struct {
+		name    string
+		method  string
+		url     string
+		errCode int
+	}{
+		{"TestInvalidMethod", "POST", "/getData/diff?sortKey=views&from=2022-05-01T00:00:00Z&to=2022-05-02T00:00:00Z", http.StatusMethodNotAllowed},
+		{"TestSortKeyMissing", "GET", "/getData/diff?from=2022-05-01T00:00:00Z&to=2022-05-02T00:00:00Z", http.StatusBadRequest},
+		{"TestFromMissing", "GET", "/getData/diff?sortKey=views&to=2022-05-02T00:00:00Z", http.StatusBadRequest},
+		{"TestToInvalid", "GET", "/getData/diff?sortKey=views&from=2022-05-01T00:00:00Z&to=abc", http.StatusBadRequest},
+		{"TestNoSnapshot", "GET", "/getData/diff?sortKey=views&from=2022-05-01T00:00:00Z&to=2022-05-02T00:00:00Z", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(GetDataDiff).ServeHTTP(rr, req)
+			assert.Equal(t, tt.errCode, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"assignment/httprequest"
+	"net/http"
+	"time"
+)
+
+// MinReadySources is the number of sources fetched successfully before the
+// server is ready, unless a snapshot can be served
+var MinReadySources = 1
+
+// Source states reported by readyz
+const (
+	SourcePending = "pending" // not fetched yet
+	SourceOK      = "ok"      // the last fetch succeeded
+	SourceFailing = "failing" // the last fetch failed
+)
+
+// Readiness is the body of the readyz response
+type Readiness struct {
+	Ready           bool          `json:"ready"`
+	SourcesReady    int           `json:"sourcesReady"`
+	MinReadySources int           `json:"minReadySources"`
+	Snapshots       int           `json:"snapshots"`
+	Sources         []SourceState `json:"sources"`
+}
+
+// SourceState is the state of a source in the readyz response
+type SourceState struct {
+	Name        string     `json:"name"`
+	State       string     `json:"state"`
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+}
+
+// Healthz handles the liveness probe, answering as long as the process serves requests
+func Healthz(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz handles the readiness probe: the server is ready once MinReadySources
+// sources have been fetched successfully, or a snapshot can be served
+func Readyz(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	readiness := readiness()
+	status := http.StatusOK
+	if !readiness.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, readiness)
+}
+
+func readiness() Readiness {
+	statuses := make(map[string]httprequest.SourceStatus)
+	for _, status := range httprequest.Statuses() {
+		statuses[status.Name] = status
+	}
+
+	readiness := Readiness{MinReadySources: MinReadySources, Sources: []SourceState{}}
+	for _, source := range sources {
+		state := SourceState{Name: source.Name, State: SourcePending}
+		if status, ok := statuses[source.Name]; ok {
+			state.LastSuccess = status.LastSuccess
+			state.LastError = status.LastError
+			state.State = SourceOK
+			if status.LastError != "" {
+				state.State = SourceFailing
+			}
+		}
+		if state.LastSuccess != nil {
+			readiness.SourcesReady++
+		}
+		readiness.Sources = append(readiness.Sources, state)
+	}
+	if Snapshots != nil {
+		readiness.Snapshots = Snapshots.Len()
+	}
+	readiness.Ready = readiness.SourcesReady >= MinReadySources || readiness.Snapshots > 0
+	return readiness
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"assignment/httprequest"
+	"assignment/models"
+	"assignment/store"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fetchContent is the actual fetch, kept before the tests replace it with mocks
+var fetchContent = httprequest.GetContent
+
+func getReadyz(t *testing.T) (int, Readiness) {
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Readyz).ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+	var readiness Readiness
+	if err := json.Unmarshal(rr.Body.Bytes(), &readiness); err != nil {
+		t.Fatal(err)
+	}
+	return rr.Code, readiness
+}
+
+func TestHealthz(t *testing.T) {
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Healthz).ServeHTTP(rr, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"status": "ok"}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(Healthz).ServeHTTP(rr, httptest.NewRequest("POST", "/healthz", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
+
+func TestReadyz(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.json")
+	if err := ioutil.WriteFile(path, []byte(`{"data": [{"url": "www.example.com/abc1", "views": 1000}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer SetSources(sources)
+	SetSources([]httprequest.Source{
+		{Name: "ready-a", URL: "file://" + filepath.ToSlash(path)},
+		{Name: "ready-b", URL: "file://" + filepath.ToSlash(filepath.Join(dir, "missing.json"))},
+	})
+	httprequest.GetContent = fetchContent
+	defer func(retries int) { httprequest.Retries = retries }(httprequest.Retries)
+	httprequest.Retries = 1
+	defer func(min int) { MinReadySources = min }(MinReadySources)
+
+	// nothing fetched yet
+	code, readiness := getReadyz(t)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.False(t, readiness.Ready)
+	assert.Equal(t, []SourceState{{Name: "ready-a", State: SourcePending}, {Name: "ready-b", State: SourcePending}}, readiness.Sources)
+
+	Refresh(context.Background())
+	code, readiness = getReadyz(t)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, readiness.Ready)
+	assert.Equal(t, 1, readiness.SourcesReady)
+	assert.Equal(t, SourceOK, readiness.Sources[0].State)
+	assert.NotNil(t, readiness.Sources[0].LastSuccess)
+	assert.Equal(t, SourceFailing, readiness.Sources[1].State)
+	assert.NotEqual(t, "", readiness.Sources[1].LastError)
+
+	// more sources are required, unless a snapshot can be served
+	MinReadySources = 2
+	code, _ = getReadyz(t)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+
+	var err error
+	Snapshots, err = store.Open(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { Snapshots = nil }()
+	err = Snapshots.Save(models.Snapshot{Time: time.Now(), UrlData: []models.UrlData{{Url: "www.example.com/abc1"}}})
+	assert.Nil(t, err)
+	code, readiness = getReadyz(t)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, readiness.Snapshots)
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"assignment/metrics"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// statusRecorder keeps the status code written to a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// observe counts a getData request and its latency by status code and sortKey
+func (r *statusRecorder) observe(req *http.Request, start time.Time) {
+	status := strconv.Itoa(r.status)
+	key := sortKeyLabel(req)
+	metrics.Requests.WithLabelValues(status, key).Inc()
+	metrics.RequestDuration.WithLabelValues(status, key).Observe(time.Since(start).Seconds())
+}
+
+// sortKeyLabel is the sortKey of the request, "invalid" for unknown keys to
+// keep the number of series bounded
+func sortKeyLabel(req *http.Request) string {
+	key := req.URL.Query().Get("sortKey")
+	if key == "" {
+		return ""
+	}
+	for _, validKey := range append(sortKeys, trendSortKeys...) {
+		if key == validKey {
+			return key
+		}
+	}
+	return "invalid"
+}
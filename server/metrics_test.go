@@ -0,0 +1,46 @@
+package server
+
+import (
+	"assignment/httprequest"
+	"assignment/metrics"
+	"assignment/models"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortKeyLabel(t *testing.T) {
+	tests := map[string]string{
+		"/getData?sortKey=views&limit=1":        "views",
+		"/getData?sortKey=viewVelocity&limit=1": "viewVelocity",
+		"/getData?sortKey=unknown&limit=1":      "invalid",
+		"/getData?limit=1":                      "",
+	}
+	for target, label := range tests {
+		assert.Equal(t, label, sortKeyLabel(httptest.NewRequest("GET", target, nil)), target)
+	}
+}
+
+func TestGetDataMetrics(t *testing.T) {
+	httprequest.GetContent = func(ctx context.Context, source httprequest.Source, siteData chan models.SiteData, wg *sync.WaitGroup) {
+		defer wg.Done()
+		siteData <- models.SiteData{UrlData: []models.UrlData{{Url: "www.example.com/abc1", Views: 1000}}}
+	}
+	requests := func(status, key string) float64 {
+		return testutil.ToFloat64(metrics.Requests.WithLabelValues(status, key))
+	}
+	ok, invalid := requests("200", "views"), requests("400", "invalid")
+
+	for _, target := range []string{"/getData?sortKey=views&limit=1", "/getData?sortKey=views&limit=2", "/getData?sortKey=x&limit=1"} {
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(GetData).ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+	}
+	assert.Equal(t, ok+2, requests("200", "views"))
+	assert.Equal(t, invalid+1, requests("400", "invalid"))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.RequestsInFlight))
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"assignment/models"
+	"context"
+	"log"
+	"time"
+)
+
+// Refresh fetches all sources once and, when every source was fetched,
+// notifies the webhooks with the merged data and saves it as a snapshot
+func Refresh(ctx context.Context) {
+	ctx, span := tracer.Start(ctx, "refresh")
+	defer span.End()
+	allSiteData, errorInAPIs := fetchAll(ctx)
+	if ctx.Err() != nil {
+		// stopped while fetching, the data is incomplete
+		return
+	}
+	if errorInAPIs {
+		// without the urls of the failed source, the webhooks would see them
+		// leave the top-N, and the snapshot would truncate the asOf rankings,
+		// the diffs and the trends
+		log.Println("Refresh incomplete: a source failed, no notification nor snapshot")
+		return
+	}
+	hooks.Notify(allSiteData.UrlData)
+
+	if Snapshots != nil {
+		snapshot := models.Snapshot{Time: time.Now(), UrlData: allSiteData.UrlData}
+		if err := Snapshots.Save(snapshot); err != nil {
+			log.Println("Error while saving snapshot: ", err)
+		}
+	}
+}
+
+// StartRefresher calls Refresh immediately and then every interval until ctx is done
+func StartRefresher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		Refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
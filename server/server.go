@@ -2,57 +2,133 @@ package server
 
 import (
 	"assignment/httprequest"
+	"assignment/metrics"
 	"assignment/models"
+	"assignment/store"
+	"context"
 	"encoding/json"
 	"errors"
 	"log"
 	"net/http"
-	"sort"
 	"strconv"
 	"sync"
+	"time"
 )
 
-var urls = []string{
-	"https://raw.githubusercontent.com/assignment132/assignment/main/duckduckgo.json",
-	"https://raw.githubusercontent.com/assignment132/assignment/main/google.json",
-	"https://raw.githubusercontent.com/assignment132/assignment/main/wikipedia.json",
+// Snapshots stores the refreshed data for 'asOf' queries, nil disables snapshots
+var Snapshots *store.Store
+
+// MaxLimit is the largest 'limit' accepted by getData
+var MaxLimit = 200
+
+// RequestTimeout limits the fetches of a getData request, 0 for no limit. It
+// must be below the write timeout of the server for the client to get a 504.
+var RequestTimeout time.Duration
+
+// sortKeys are the fields of the upstream data that can be sorted on
+var sortKeys = []string{"relevanceScore", "views"}
+
+// trendSortKeys are the trend fields computed from snapshots that can be sorted on
+var trendSortKeys = []string{"viewVelocity", "viewAcceleration", "relevanceDrift"}
+
+var sources = []httprequest.Source{
+	{
+		Name: "duckduckgo",
+		URL:  "https://raw.githubusercontent.com/assignment132/assignment/main/duckduckgo.json",
+	},
+	{
+		Name: "google",
+		URL:  "https://raw.githubusercontent.com/assignment132/assignment/main/google.json",
+	},
+	{
+		Name: "wikipedia",
+		URL:  "https://raw.githubusercontent.com/assignment132/assignment/main/wikipedia.json",
+	},
+}
+
+// SetSources replaces the default sources queried by getData
+func SetSources(s []httprequest.Source) {
+	sources = s
+	httprequest.SetRateLimits(s)
+}
+
+// SourceList returns the sources queried by getData
+func SourceList() []httprequest.Source {
+	return sources
 }
 
 // GetData handles getData request and writes the response to ResponseWriter
 func GetData(w http.ResponseWriter, req *http.Request) {
+	start := time.Now()
+	metrics.RequestsInFlight.Inc()
+	defer metrics.RequestsInFlight.Dec()
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	defer recorder.observe(req, start)
+	w = recorder
+	req, span := startSpan(req, "/getData")
+	defer func() { endSpan(span, recorder.status) }()
+
 	key, limit, errCode, err := validateRequest(req)
 	if err != nil {
 		http.Error(w, err.Error(), errCode)
 		log.Println(err)
 		return
 	}
-	siteData := make(chan models.SiteData)
-
-	var wg sync.WaitGroup
-	for _, url := range urls {
-		wg.Add(1)
-		go httprequest.GetContent(url, siteData, &wg)
+	asOf, ok, err := parseTime(req, "asOf")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	window, err := parseWindow(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		log.Println(err)
+		return
+	}
+	if Snapshots == nil && isTrendSortKey(key) {
+		http.Error(w, "url parameter value for 'sortKey' is not supported: snapshots are disabled", http.StatusBadRequest)
+		return
 	}
-
-	// close the channel in the background
-	go func() {
-		wg.Wait()
-		close(siteData)
-	}()
 
 	var allSiteData models.SiteDataResponse
-	errorInAPIs := false
-	for data := range siteData {
-		if data.URLError != nil {
-			errorInAPIs = true
+	if ok {
+		allSiteData, errCode, err = snapshotData(asOf)
+		if err != nil {
+			http.Error(w, err.Error(), errCode)
+			log.Println(err)
+			return
+		}
+	} else {
+		ctx := req.Context()
+		if RequestTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, RequestTimeout)
+			defer cancel()
+		}
+		var errorInAPIs bool
+		allSiteData, errorInAPIs = fetchAll(ctx)
+		if len(allSiteData.UrlData) == 0 && errorInAPIs {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				http.Error(w, "Gateway Timeout", http.StatusGatewayTimeout)
+				return
+			}
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
 		}
-		allSiteData.UrlData = append(allSiteData.UrlData, data.UrlData...)
 	}
-	allSiteData.Count = len(allSiteData.UrlData)
 
-	if len(allSiteData.UrlData) == 0 && errorInAPIs {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+	// the trends are computed at the time of the data
+	dataTime := time.Now()
+	if allSiteData.SnapshotTime != nil {
+		dataTime = *allSiteData.SnapshotTime
+	}
+	if wantsTrends(req, key) {
+		if err := applyTrends(allSiteData.UrlData, dataTime, window); err != nil {
+			http.Error(w, "Error while computing trends: "+err.Error(), http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
 	}
 
 	sortKey(allSiteData, key)
@@ -72,18 +148,43 @@ func GetData(w http.ResponseWriter, req *http.Request) {
 	log.Println("Response: ", allSiteData)
 }
 
+// fetchAll queries all sources concurrently and merges their data.
+// The returned bool reports whether any of the sources failed.
+func fetchAll(ctx context.Context) (models.SiteDataResponse, bool) {
+	siteData := make(chan models.SiteData)
+
+	var wg sync.WaitGroup
+	for _, source := range sources {
+		wg.Add(1)
+		go httprequest.GetContent(ctx, source, siteData, &wg)
+	}
+
+	// close the channel in the background
+	go func() {
+		wg.Wait()
+		close(siteData)
+	}()
+
+	var allSiteData models.SiteDataResponse
+	errorInAPIs := false
+	for data := range siteData {
+		if data.URLError != nil {
+			errorInAPIs = true
+		}
+		allSiteData.UrlData = append(allSiteData.UrlData, data.UrlData...)
+	}
+	allSiteData.Count = len(allSiteData.UrlData)
+	return allSiteData, errorInAPIs
+}
+
 func validateRequest(req *http.Request) (string, int, int, error) {
 	if req.Method != "GET" {
 		return "", 0, http.StatusMethodNotAllowed, errors.New("method not allowed")
 	}
 
-	keys, ok := req.URL.Query()["sortKey"]
-	if !ok || len(keys[0]) < 1 {
-		return "", 0, http.StatusBadRequest, errors.New("url parameter 'sortKey' is missing")
-	}
-	key := keys[0]
-	if key != "relevanceScore" && key != "views" {
-		return "", 0, http.StatusBadRequest, errors.New("url parameter value for 'sortKey' is invalid")
+	key, err := validateSortKey(req, append(sortKeys, trendSortKeys...))
+	if err != nil {
+		return "", 0, http.StatusBadRequest, err
 	}
 
 	limits, ok := req.URL.Query()["limit"]
@@ -94,21 +195,59 @@ func validateRequest(req *http.Request) (string, int, int, error) {
 	if err != nil {
 		return "", 0, http.StatusBadRequest, errors.New("Error while reading limit value: " + err.Error())
 	}
-	if limit < 1 || limit > 200 {
+	if limit < 1 || limit > MaxLimit {
 		return "", 0, http.StatusBadRequest, errors.New("url parameter value for 'limit' is invalid")
 	}
 	return key, limit, 0, nil
 }
 
-func sortKey(data models.SiteDataResponse, key string) {
-	sort.Slice(data.UrlData, func(i, j int) bool {
-		var result bool
-		switch key {
-		case "relevanceScore":
-			result = data.UrlData[i].RelevanceScore < data.UrlData[j].RelevanceScore
-		case "views":
-			result = data.UrlData[i].Views < data.UrlData[j].Views
+func validateSortKey(req *http.Request, validKeys []string) (string, error) {
+	keys, ok := req.URL.Query()["sortKey"]
+	if !ok || len(keys[0]) < 1 {
+		return "", errors.New("url parameter 'sortKey' is missing")
+	}
+	key := keys[0]
+	for _, validKey := range validKeys {
+		if key == validKey {
+			return key, nil
 		}
-		return result
-	})
+	}
+	return "", errors.New("url parameter value for 'sortKey' is invalid")
+}
+
+// parseTime reads the optional RFC3339 url parameter name.
+// The returned bool reports whether the parameter is present.
+func parseTime(req *http.Request, name string) (time.Time, bool, error) {
+	value := req.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, errors.New("url parameter value for '" + name + "' is invalid: " + err.Error())
+	}
+	return t, true, nil
+}
+
+// snapshotData returns the data of the nearest snapshot at or before asOf
+func snapshotData(asOf time.Time) (models.SiteDataResponse, int, error) {
+	var allSiteData models.SiteDataResponse
+	if Snapshots == nil {
+		return allSiteData, http.StatusBadRequest, errors.New("snapshots are disabled")
+	}
+	snapshot, err := Snapshots.At(asOf)
+	if err == store.ErrNotFound {
+		return allSiteData, http.StatusNotFound, errors.New("no snapshot found at or before " + asOf.Format(time.RFC3339))
+	}
+	if err != nil {
+		return allSiteData, http.StatusInternalServerError, errors.New("Error while reading snapshot: " + err.Error())
+	}
+	allSiteData.UrlData = snapshot.UrlData
+	allSiteData.Count = len(snapshot.UrlData)
+	allSiteData.SnapshotTime = &snapshot.Time
+	return allSiteData, 0, nil
+}
+
+func sortKey(data models.SiteDataResponse, key string) {
+	models.SortByKey(data.UrlData, key)
 }
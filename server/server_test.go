@@ -3,6 +3,8 @@ package server
 import (
 	"assignment/httprequest"
 	"assignment/models"
+	"assignment/store"
+	"context"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
@@ -11,15 +13,16 @@ import (
 	"net/url"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestGetData(t *testing.T) {
-	httprequest.GetContent = func(url string, siteData chan models.SiteData, wg *sync.WaitGroup) {
+	httprequest.GetContent = func(ctx context.Context, source httprequest.Source, siteData chan models.SiteData, wg *sync.WaitGroup) {
 		defer wg.Done()
-		switch url {
-		case urls[0]:
+		switch source.URL {
+		case sources[0].URL:
 			siteData <- models.SiteData{
 				UrlData: []models.UrlData{
 					{
@@ -39,7 +42,7 @@ func TestGetData(t *testing.T) {
 					},
 				},
 			}
-		case urls[1]:
+		case sources[1].URL:
 			siteData <- models.SiteData{
 				UrlData: []models.UrlData{
 					{
@@ -59,7 +62,7 @@ func TestGetData(t *testing.T) {
 					},
 				},
 			}
-		case urls[2]:
+		case sources[2].URL:
 			siteData <- models.SiteData{
 				UrlData: []models.UrlData{
 					{
@@ -214,14 +217,14 @@ func TestGetData(t *testing.T) {
 }
 
 func TestGetDataReturnsNoData(t *testing.T) {
-	httprequest.GetContent = func(url string, siteData chan models.SiteData, wg *sync.WaitGroup) {
+	httprequest.GetContent = func(ctx context.Context, source httprequest.Source, siteData chan models.SiteData, wg *sync.WaitGroup) {
 		defer wg.Done()
-		switch url {
-		case urls[0]:
+		switch source.URL {
+		case sources[0].URL:
 			siteData <- models.SiteData{}
-		case urls[1]:
+		case sources[1].URL:
 			siteData <- models.SiteData{}
-		case urls[2]:
+		case sources[2].URL:
 			siteData <- models.SiteData{}
 		}
 	}
@@ -243,14 +246,14 @@ func TestGetDataReturnsNoData(t *testing.T) {
 }
 
 func TestGetDataReturnsDataWhenErrorInSomeAPIs(t *testing.T) {
-	httprequest.GetContent = func(url string, siteData chan models.SiteData, wg *sync.WaitGroup) {
+	httprequest.GetContent = func(ctx context.Context, source httprequest.Source, siteData chan models.SiteData, wg *sync.WaitGroup) {
 		defer wg.Done()
-		switch url {
-		case urls[0]:
+		switch source.URL {
+		case sources[0].URL:
 			siteData <- models.SiteData{
 				URLError: errors.New("Internal error"),
 			}
-		case urls[1]:
+		case sources[1].URL:
 			siteData <- models.SiteData{
 				UrlData: []models.UrlData{
 					{
@@ -265,7 +268,7 @@ func TestGetDataReturnsDataWhenErrorInSomeAPIs(t *testing.T) {
 					},
 				},
 			}
-		case urls[2]:
+		case sources[2].URL:
 			siteData <- models.SiteData{
 				UrlData: []models.UrlData{
 					{
@@ -409,16 +412,16 @@ func TestGetDataInvalidRequestParams3(t *testing.T) {
 }
 
 func TestGetDataReturnsError(t *testing.T) {
-	httprequest.GetContent = func(url string, siteData chan models.SiteData, wg *sync.WaitGroup) {
+	httprequest.GetContent = func(ctx context.Context, source httprequest.Source, siteData chan models.SiteData, wg *sync.WaitGroup) {
 		defer wg.Done()
-		switch url {
-		case urls[0]:
+		switch source.URL {
+		case sources[0].URL:
 			siteData <- models.SiteData{}
-		case urls[1]:
+		case sources[1].URL:
 			siteData <- models.SiteData{
 				URLError: errors.New("Some error"),
 			}
-		case urls[2]:
+		case sources[2].URL:
 			siteData <- models.SiteData{}
 		}
 	}
@@ -439,6 +442,125 @@ func TestGetDataReturnsError(t *testing.T) {
 	}
 }
 
+func TestGetDataRequestTimeout(t *testing.T) {
+	httprequest.GetContent = func(ctx context.Context, source httprequest.Source, siteData chan models.SiteData, wg *sync.WaitGroup) {
+		defer wg.Done()
+		<-ctx.Done()
+		siteData <- models.SiteData{URLError: ctx.Err()}
+	}
+	defer func(timeout time.Duration) { RequestTimeout = timeout }(RequestTimeout)
+	RequestTimeout = 50 * time.Millisecond
+
+	req := httptest.NewRequest("GET", "/getData?sortKey=views&limit=5", nil)
+	rr := httptest.NewRecorder()
+	start := time.Now()
+	GetData(rr, req)
+
+	assert.Equal(t, http.StatusGatewayTimeout, rr.Code)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
+
+func TestGetDataAsOf(t *testing.T) {
+	httprequest.GetContent = func(ctx context.Context, source httprequest.Source, siteData chan models.SiteData, wg *sync.WaitGroup) {
+		defer wg.Done()
+		siteData <- models.SiteData{
+			URLError: errors.New("Some error"),
+		}
+	}
+
+	var err error
+	Snapshots, err = store.Open(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { Snapshots = nil }()
+
+	yesterday := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	err = Snapshots.Save(models.Snapshot{
+		Time: yesterday,
+		UrlData: []models.UrlData{
+			{
+				Url:            "www.example.com/abc2",
+				Views:          2000,
+				RelevanceScore: 0.5,
+			},
+			{
+				Url:            "www.example.com/abc1",
+				Views:          1000,
+				RelevanceScore: 0.4,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", "/getData?sortKey=views&limit=5&asOf=2022-05-02T00:00:00Z", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(GetData)
+
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("TestGetDataAsOf: handler returned wrong status code: got %v want %v",
+			status, http.StatusOK)
+	}
+
+	var data models.SiteDataResponse
+	err = json.Unmarshal(rr.Body.Bytes(), &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, data.Count)
+	assert.Equal(t, "www.example.com/abc1", data.UrlData[0].Url)
+	assert.True(t, yesterday.Equal(*data.SnapshotTime))
+
+	// No snapshot before asOf
+	req, err = http.NewRequest("GET", "/getData?sortKey=views&limit=5&asOf=2022-04-01T00:00:00Z", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("TestGetDataAsOf: handler returned wrong status code: got %v want %v",
+			status, http.StatusNotFound)
+	}
+
+	// Invalid asOf
+	req, err = http.NewRequest("GET", "/getData?sortKey=views&limit=5&asOf=yesterday", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("TestGetDataAsOf: handler returned wrong status code: got %v want %v",
+			status, http.StatusBadRequest)
+	}
+}
+
+func TestGetDataAsOfSnapshotsDisabled(t *testing.T) {
+	req, err := http.NewRequest("GET", "/getData?sortKey=views&limit=5&asOf=2022-05-02T00:00:00Z", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(GetData)
+
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("TestGetDataAsOfSnapshotsDisabled: handler returned wrong status code: got %v want %v",
+			status, http.StatusBadRequest)
+	}
+}
+
 func Test_validateRequest(t *testing.T) {
 	type args struct {
 		req *http.Request
@@ -466,6 +588,21 @@ func Test_validateRequest(t *testing.T) {
 			errCode: 0,
 			wantErr: false,
 		},
+		{
+			name: "TestTrendSortKey",
+			args: args{
+				req: &http.Request{
+					Method: "GET",
+					URL: &url.URL{
+						RawQuery: "sortKey=viewVelocity&limit=5",
+					},
+				},
+			},
+			key:     "viewVelocity",
+			limit:   5,
+			errCode: 0,
+			wantErr: false,
+		},
 		{
 			name: "TestInvalidMethod",
 			args: args{
@@ -0,0 +1,15 @@
+package server
+
+import (
+	"assignment/httprequest"
+	"net/http"
+)
+
+// Sources handles sources request and writes the status of every source to ResponseWriter
+func Sources(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, httprequest.Statuses())
+}
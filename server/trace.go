@@ -0,0 +1,35 @@
+package server
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer starts the spans of the handlers and of the background refresh
+var tracer = otel.Tracer("assignment/server")
+
+// startSpan starts the server span of req, continuing the trace of its W3C
+// trace context headers, and returns req with the span in its context
+func startSpan(req *http.Request, route string) (*http.Request, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+	ctx, span := tracer.Start(ctx, req.Method+" "+route, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+		semconv.HTTPMethodKey.String(req.Method),
+		semconv.HTTPTargetKey.String(req.URL.RequestURI()),
+		semconv.HTTPRouteKey.String(route),
+	))
+	return req.WithContext(ctx), span
+}
+
+// endSpan records the status code of the response on span and ends it
+func endSpan(span trace.Span, status int) {
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	span.End()
+}
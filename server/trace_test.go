@@ -0,0 +1,66 @@
+package server
+
+import (
+	"assignment/httprequest"
+	"assignment/models"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestGetDataSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	}()
+
+	var fetchSpans []trace.SpanContext
+	var mu sync.Mutex
+	httprequest.GetContent = func(ctx context.Context, source httprequest.Source, siteData chan models.SiteData, wg *sync.WaitGroup) {
+		defer wg.Done()
+		mu.Lock()
+		fetchSpans = append(fetchSpans, trace.SpanContextFromContext(ctx))
+		mu.Unlock()
+		siteData <- models.SiteData{URLError: context.DeadlineExceeded}
+	}
+
+	req := httptest.NewRequest("GET", "/getData?sortKey=views&limit=1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetData).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "GET /getData", span.Name())
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+	// the span continues the trace of the caller
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+	assert.Equal(t, codes.Error, span.Status().Code)
+	for _, kv := range span.Attributes() {
+		if kv.Key == "http.status_code" {
+			assert.Equal(t, int64(http.StatusInternalServerError), kv.Value.AsInt64())
+		}
+	}
+
+	// the sources are fetched within the span of the request
+	assert.Len(t, fetchSpans, len(sources))
+	for _, fetchSpan := range fetchSpans {
+		assert.Equal(t, span.SpanContext().SpanID(), fetchSpan.SpanID())
+	}
+}
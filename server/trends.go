@@ -0,0 +1,107 @@
+package server
+
+import (
+	"assignment/models"
+	"assignment/store"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultTrendWindow = 24 * time.Hour
+	minTrendWindow     = time.Minute
+)
+
+func isTrendSortKey(key string) bool {
+	for _, trendKey := range trendSortKeys {
+		if key == trendKey {
+			return true
+		}
+	}
+	return false
+}
+
+// wantsTrends reports whether the request sorts on a trend or gives a 'window',
+// the trends read two snapshots from the disk and are skipped otherwise
+func wantsTrends(req *http.Request, key string) bool {
+	return isTrendSortKey(key) || req.URL.Query().Get("window") != ""
+}
+
+// parseWindow reads the optional 'window' url parameter, the lookback for trends
+func parseWindow(req *http.Request) (time.Duration, error) {
+	value := req.URL.Query().Get("window")
+	if value == "" {
+		return defaultTrendWindow, nil
+	}
+	window, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.New("url parameter value for 'window' is invalid: " + err.Error())
+	}
+	if window < minTrendWindow {
+		return 0, errors.New("url parameter value for 'window' is invalid")
+	}
+	return window, nil
+}
+
+// applyTrends sets the trend fields of data at time now from the snapshots in the
+// window before it. For each url, with v the views and r the relevanceScore:
+//
+//	viewVelocity     = (v(now) - v(start)) / hours(now - start)
+//	viewAcceleration = (velocity(mid, now) - velocity(start, mid)) / (hours(now - start) / 2)
+//	relevanceDrift   = (r(now) - r(start)) / hours(now - start)
+//
+// where start is the oldest snapshot in the window and mid the one nearest before
+// the middle of start and now. Urls missing from those snapshots get no trend.
+func applyTrends(data []models.UrlData, now time.Time, window time.Duration) error {
+	if Snapshots == nil {
+		return nil
+	}
+	start, err := Snapshots.After(now.Add(-window))
+	if err == store.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	hours := now.Sub(start.Time).Hours()
+	if hours <= 0 {
+		return nil
+	}
+	mid, err := Snapshots.At(start.Time.Add(now.Sub(start.Time) / 2))
+	if err != nil {
+		return err
+	}
+	firstHours := mid.Time.Sub(start.Time).Hours()
+	secondHours := now.Sub(mid.Time).Hours()
+
+	startData := byUrl(start.UrlData)
+	midData := byUrl(mid.UrlData)
+	for i := range data {
+		old, ok := startData[data[i].Url]
+		if !ok {
+			continue
+		}
+		data[i].ViewVelocity = float64(data[i].Views-old.Views) / hours
+		data[i].RelevanceDrift = (data[i].RelevanceScore - old.RelevanceScore) / hours
+
+		middle, ok := midData[data[i].Url]
+		if !ok || firstHours <= 0 || secondHours <= 0 {
+			continue
+		}
+		firstVelocity := float64(middle.Views-old.Views) / firstHours
+		secondVelocity := float64(data[i].Views-middle.Views) / secondHours
+		data[i].ViewAcceleration = (secondVelocity - firstVelocity) / (hours / 2)
+	}
+	return nil
+}
+
+func byUrl(data []models.UrlData) map[string]models.UrlData {
+	urls := make(map[string]models.UrlData, len(data))
+	for _, urlData := range data {
+		if _, ok := urls[urlData.Url]; !ok {
+			urls[urlData.Url] = urlData
+		}
+	}
+	return urls
+}
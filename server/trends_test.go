@@ -0,0 +1,181 @@
+package server
+
+import (
+	"assignment/httprequest"
+	"assignment/models"
+	"assignment/store"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func openTrendSnapshots(t *testing.T, now time.Time) {
+	var err error
+	Snapshots, err = store.Open(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshots := []models.Snapshot{
+		{
+			Time: now.Add(-4 * time.Hour),
+			UrlData: []models.UrlData{
+				{Url: "www.example.com/abc1", Views: 0, RelevanceScore: 0.1},
+			},
+		},
+		{
+			Time: now.Add(-2 * time.Hour),
+			UrlData: []models.UrlData{
+				{Url: "www.example.com/abc1", Views: 1000, RelevanceScore: 0.2},
+				{Url: "www.example.com/abc2", Views: 1000, RelevanceScore: 0.5},
+			},
+		},
+		{
+			Time: now.Add(-1 * time.Hour),
+			UrlData: []models.UrlData{
+				{Url: "www.example.com/abc1", Views: 1200, RelevanceScore: 0.25},
+				{Url: "www.example.com/abc2", Views: 1500, RelevanceScore: 0.5},
+			},
+		},
+	}
+	for _, snapshot := range snapshots {
+		if err := Snapshots.Save(snapshot); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func Test_applyTrends(t *testing.T) {
+	now := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	openTrendSnapshots(t, now)
+	defer func() { Snapshots = nil }()
+
+	data := []models.UrlData{
+		{Url: "www.example.com/abc1", Views: 1600, RelevanceScore: 0.3},
+		{Url: "www.example.com/abc2", Views: 3000, RelevanceScore: 0.4},
+		{Url: "www.example.com/abc3", Views: 9000, RelevanceScore: 0.9},
+	}
+
+	// window of 3h: start is 2h ago and mid is 1h ago
+	err := applyTrends(data, now, 3*time.Hour)
+	assert.Nil(t, err)
+
+	assert.InDelta(t, 300, data[0].ViewVelocity, 1e-9)
+	assert.InDelta(t, (400-200)/1.0, data[0].ViewAcceleration, 1e-9)
+	assert.InDelta(t, 0.05, data[0].RelevanceDrift, 1e-9)
+
+	assert.InDelta(t, 1000, data[1].ViewVelocity, 1e-9)
+	assert.InDelta(t, (1500-500)/1.0, data[1].ViewAcceleration, 1e-9)
+	assert.InDelta(t, -0.05, data[1].RelevanceDrift, 1e-9)
+
+	// no history for abc3
+	assert.Equal(t, 0.0, data[2].ViewVelocity)
+	assert.Equal(t, 0.0, data[2].ViewAcceleration)
+	assert.Equal(t, 0.0, data[2].RelevanceDrift)
+
+	// window of 5h: start is 4h ago, where abc2 is missing
+	data[0].ViewVelocity, data[1].ViewVelocity = 0, 0
+	err = applyTrends(data, now, 5*time.Hour)
+	assert.Nil(t, err)
+	assert.InDelta(t, 400, data[0].ViewVelocity, 1e-9)
+	assert.Equal(t, 0.0, data[1].ViewVelocity)
+}
+
+func TestGetDataSortOnViewVelocity(t *testing.T) {
+	httprequest.GetContent = func(ctx context.Context, source httprequest.Source, siteData chan models.SiteData, wg *sync.WaitGroup) {
+		defer wg.Done()
+		if source.URL == sources[0].URL {
+			siteData <- models.SiteData{
+				UrlData: []models.UrlData{
+					{Url: "www.example.com/abc1", Views: 1600, RelevanceScore: 0.3},
+					{Url: "www.example.com/abc2", Views: 3000, RelevanceScore: 0.4},
+				},
+			}
+			return
+		}
+		siteData <- models.SiteData{}
+	}
+	openTrendSnapshots(t, time.Now())
+	defer func() { Snapshots = nil }()
+
+	req, err := http.NewRequest("GET", "/getData?sortKey=viewVelocity&limit=5&window=3h", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetData).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("TestGetDataSortOnViewVelocity: handler returned wrong status code: got %v want %v",
+			status, http.StatusOK)
+	}
+
+	var data models.SiteDataResponse
+	err = json.Unmarshal(rr.Body.Bytes(), &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, data.Count)
+	assert.Equal(t, "www.example.com/abc1", data.UrlData[0].Url)
+	assert.InDelta(t, 300, data.UrlData[0].ViewVelocity, 1)
+	assert.Equal(t, "www.example.com/abc2", data.UrlData[1].Url)
+	assert.InDelta(t, 1000, data.UrlData[1].ViewVelocity, 1)
+}
+
+func TestGetDataTrendsOnRequest(t *testing.T) {
+	httprequest.GetContent = func(ctx context.Context, source httprequest.Source, siteData chan models.SiteData, wg *sync.WaitGroup) {
+		defer wg.Done()
+		if source.URL == sources[0].URL {
+			siteData <- models.SiteData{UrlData: []models.UrlData{{Url: "www.example.com/abc1", Views: 1600, RelevanceScore: 0.3}}}
+			return
+		}
+		siteData <- models.SiteData{}
+	}
+	openTrendSnapshots(t, time.Now())
+	defer func() { Snapshots = nil }()
+
+	get := func(target string) models.SiteDataResponse {
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(GetData).ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var data models.SiteDataResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &data); err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	// without a trend sortKey or a window the snapshots are not read
+	data := get("/getData?sortKey=views&limit=5")
+	assert.Equal(t, 0.0, data.UrlData[0].ViewVelocity)
+
+	data = get("/getData?sortKey=views&limit=5&window=3h")
+	assert.InDelta(t, 300, data.UrlData[0].ViewVelocity, 1)
+}
+
+func TestGetDataTrendsInvalidRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"TestTrendsSnapshotsDisabled", "/getData?sortKey=viewVelocity&limit=5"},
+		{"TestWindowInvalid", "/getData?sortKey=views&limit=5&window=abc"},
+		{"TestWindowTooSmall", "/getData?sortKey=views&limit=5&window=1s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(GetData).ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+}
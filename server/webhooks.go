@@ -0,0 +1,86 @@
+package server
+
+import (
+	"assignment/webhook"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+var hooks = newRegistry()
+
+// newRegistry returns a registry whose webhooks are limited by MaxLimit, as
+// checked by Webhooks, instead of a maximum of its own
+func newRegistry() *webhook.Registry {
+	r := webhook.NewRegistry()
+	r.MaxN = 0
+	return r
+}
+
+// SetWebhookLimits sets the largest number of webhooks and the only hosts they
+// can be registered for, any host with a public address when empty
+func SetWebhookLimits(maxHooks int, hosts []string) {
+	hooks.MaxHooks = maxHooks
+	hooks.Hosts = hosts
+}
+
+// StopWebhooks stops notifying the webhooks and waits for the in-flight
+// deliveries until ctx is done
+func StopWebhooks(ctx context.Context) error {
+	return hooks.Close(ctx)
+}
+
+// Webhooks handles the webhooks request:
+// GET lists the webhooks, POST registers one and DELETE removes the one given by 'id'
+func Webhooks(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, hooks.List())
+	case http.MethodPost:
+		var hook webhook.Webhook
+		if err := json.NewDecoder(req.Body).Decode(&hook); err != nil {
+			http.Error(w, "Error while reading webhook: "+err.Error(), http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+		if hook.N > MaxLimit {
+			// the ranking cannot be longer than the one served by getData
+			http.Error(w, "webhook 'n' is invalid", http.StatusBadRequest)
+			return
+		}
+		hook, err := hooks.Register(hook)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			log.Println(err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, hook)
+	case http.MethodDelete:
+		id := req.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "url parameter 'id' is missing", http.StatusBadRequest)
+			return
+		}
+		if !hooks.Remove(id) {
+			http.Error(w, "webhook not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeJSON writes v as the JSON response body with the status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	jsonResp, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		log.Println("Error happened in JSON marshal: ", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(jsonResp)
+}
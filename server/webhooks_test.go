@@ -0,0 +1,234 @@
+package server
+
+import (
+	"assignment/httprequest"
+	"assignment/models"
+	"assignment/store"
+	"assignment/webhook"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhooks(t *testing.T) {
+	httprequest.GetContent = func(ctx context.Context, source httprequest.Source, siteData chan models.SiteData, wg *sync.WaitGroup) {
+		defer wg.Done()
+		if source.URL == sources[0].URL {
+			siteData <- models.SiteData{
+				UrlData: []models.UrlData{
+					{
+						Url:            "www.example.com/abc1",
+						Views:          1000,
+						RelevanceScore: 0.4,
+					},
+				},
+			}
+			return
+		}
+		siteData <- models.SiteData{}
+	}
+
+	var mu sync.Mutex
+	var payloads []webhook.Payload
+	receiver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var payload webhook.Payload
+		json.NewDecoder(req.Body).Decode(&payload)
+		mu.Lock()
+		payloads = append(payloads, payload)
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	useRegistry(t)
+	handler := http.HandlerFunc(Webhooks)
+
+	// Register a webhook
+	req := httptest.NewRequest("POST", "/webhooks",
+		strings.NewReader(`{"url": "`+receiver.URL+`", "sortKey": "views", "n": 3, "secret": "abc"}`))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	var hook webhook.Webhook
+	err := json.Unmarshal(rr.Body.Bytes(), &hook)
+	assert.Nil(t, err)
+	assert.Equal(t, "", hook.Secret)
+
+	Refresh(context.Background())
+	hooks.Wait()
+	assert.Equal(t, 1, len(payloads))
+	assert.Equal(t, "www.example.com/abc1", payloads[0].Top[0].Url)
+
+	// List the webhooks with their attempts
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/webhooks", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var list []webhook.Webhook
+	err = json.Unmarshal(rr.Body.Bytes(), &list)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(list))
+	assert.Equal(t, 1, len(list[0].Attempts))
+
+	// Remove the webhook
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("DELETE", "/webhooks?id="+hook.ID, nil))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("DELETE", "/webhooks?id="+hook.ID, nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestWebhooksSourceFailedOnce(t *testing.T) {
+	failing := false
+	httprequest.GetContent = func(ctx context.Context, source httprequest.Source, siteData chan models.SiteData, wg *sync.WaitGroup) {
+		defer wg.Done()
+		switch source.URL {
+		case sources[0].URL:
+			siteData <- models.SiteData{UrlData: []models.UrlData{{Url: "www.example.com/abc1", Views: 1000}}}
+		case sources[1].URL:
+			if failing {
+				siteData <- models.SiteData{URLError: errors.New("Some error")}
+				return
+			}
+			siteData <- models.SiteData{UrlData: []models.UrlData{{Url: "www.example.com/abc2", Views: 500}}}
+		default:
+			siteData <- models.SiteData{}
+		}
+	}
+
+	var mu sync.Mutex
+	var payloads []webhook.Payload
+	receiver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var payload webhook.Payload
+		json.NewDecoder(req.Body).Decode(&payload)
+		mu.Lock()
+		payloads = append(payloads, payload)
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	useRegistry(t)
+	_, err := hooks.Register(webhook.Webhook{URL: receiver.URL, SortKey: "views", N: 3})
+	assert.Nil(t, err)
+
+	Refresh(context.Background())
+	hooks.Wait()
+	assert.Equal(t, 1, len(payloads))
+
+	// the urls of the failed source neither leave the top-N nor enter it again
+	failing = true
+	Refresh(context.Background())
+	failing = false
+	Refresh(context.Background())
+	hooks.Wait()
+	assert.Equal(t, 1, len(payloads))
+}
+
+// useRegistry replaces the registry of the webhooks for the duration of the test
+func useRegistry(t *testing.T) {
+	saved := hooks
+	hooks = newRegistry()
+	hooks.Backoff = time.Millisecond
+	// the test receivers listen on loopback
+	hooks.AllowPrivate = true
+	t.Cleanup(func() { hooks = saved })
+}
+
+func TestWebhooksInvalidRequest(t *testing.T) {
+	useRegistry(t)
+	handler := http.HandlerFunc(Webhooks)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/webhooks", strings.NewReader(`{"url": "http://example.com", "sortKey": "abc", "n": 3}`)))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/webhooks", strings.NewReader(`invalid`)))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// n is limited by the configured MaxLimit of getData
+	defer func(limit int) { MaxLimit = limit }(MaxLimit)
+	MaxLimit = 10
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/webhooks", strings.NewReader(`{"url": "http://example.com", "sortKey": "views", "n": 11}`)))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "webhook 'n' is invalid\n", rr.Body.String())
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/webhooks", strings.NewReader(`{"url": "http://example.com", "sortKey": "views", "n": 10}`)))
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	// the receivers cannot be private addresses, nor more than the limit
+	hooks.AllowPrivate = false
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/webhooks", strings.NewReader(`{"url": "http://169.254.169.254/latest", "sortKey": "views", "n": 1}`)))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	SetWebhookLimits(1, nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/webhooks", strings.NewReader(`{"url": "http://example.com", "sortKey": "views", "n": 1}`)))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "too many webhooks, at most 1\n", rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("PUT", "/webhooks", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
+
+func TestRefreshSourceFailed(t *testing.T) {
+	failing := true
+	httprequest.GetContent = func(ctx context.Context, source httprequest.Source, siteData chan models.SiteData, wg *sync.WaitGroup) {
+		defer wg.Done()
+		if source.URL == sources[1].URL && failing {
+			siteData <- models.SiteData{URLError: errors.New("Some error")}
+			return
+		}
+		siteData <- models.SiteData{UrlData: []models.UrlData{{Url: source.URL, Views: 1000}}}
+	}
+
+	var err error
+	Snapshots, err = store.Open(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { Snapshots = nil }()
+
+	// the data of the failed source would be missing from the snapshot
+	Refresh(context.Background())
+	assert.Equal(t, 0, Snapshots.Len())
+
+	failing = false
+	Refresh(context.Background())
+	assert.Equal(t, 1, Snapshots.Len())
+}
+
+func TestRefreshStopped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	httprequest.GetContent = func(ctx context.Context, source httprequest.Source, siteData chan models.SiteData, wg *sync.WaitGroup) {
+		defer wg.Done()
+		if source.URL == sources[0].URL {
+			siteData <- models.SiteData{UrlData: []models.UrlData{{Url: "www.example.com/abc1", Views: 1000}}}
+			return
+		}
+		// the other sources are still fetching when the server stops
+		cancel()
+		siteData <- models.SiteData{URLError: ctx.Err()}
+	}
+
+	var err error
+	Snapshots, err = store.Open(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { Snapshots = nil }()
+
+	StartRefresher(ctx, time.Hour)
+	assert.Equal(t, 0, Snapshots.Len())
+}
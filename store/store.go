@@ -0,0 +1,176 @@
+package store
+
+import (
+	"assignment/models"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ext = ".json"
+
+// tmpPrefix starts the names of the files written before they are renamed to a snapshot
+const tmpPrefix = "tmp-"
+
+// ErrNotFound is returned when no snapshot matches a query
+var ErrNotFound = errors.New("snapshot not found")
+
+// Store keeps timestamped snapshots of the merged data as JSON files in a directory.
+// Snapshots older than MaxAge are removed, as are the oldest ones beyond MaxCount.
+// A zero MaxAge or MaxCount disables that part of the retention policy.
+type Store struct {
+	Dir      string
+	MaxAge   time.Duration
+	MaxCount int
+
+	mu    sync.Mutex
+	times []time.Time // sorted times of the stored snapshots
+}
+
+// Open creates dir if needed, indexes the snapshots already stored in it and
+// removes the temporary files left by a crash while saving
+func Open(dir string, maxAge time.Duration, maxCount int) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{Dir: dir, MaxAge: maxAge, MaxCount: maxCount}
+	for _, file := range files {
+		name := file.Name()
+		if !file.IsDir() && strings.HasPrefix(name, tmpPrefix) {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil {
+				log.Println("Error while removing temporary file: ", err)
+			}
+			continue
+		}
+		if file.IsDir() || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		nanos, err := strconv.ParseInt(strings.TrimSuffix(name, ext), 10, 64)
+		if err != nil {
+			log.Println("Skipping unknown file in snapshot store: ", name)
+			continue
+		}
+		s.times = append(s.times, time.Unix(0, nanos).UTC())
+	}
+	sort.Slice(s.times, func(i, j int) bool { return s.times[i].Before(s.times[j]) })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(time.Now())
+	return s, nil
+}
+
+// Save writes the snapshot and applies the retention policy
+func (s *Store) Save(snapshot models.Snapshot) error {
+	snapshot.Time = snapshot.Time.UTC()
+	body, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// write to a temporary file first so a crash never leaves a partial snapshot
+	tmp, err := ioutil.TempFile(s.Dir, tmpPrefix)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path(snapshot.Time)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	i := sort.Search(len(s.times), func(i int) bool { return !s.times[i].Before(snapshot.Time) })
+	if i == len(s.times) || !s.times[i].Equal(snapshot.Time) {
+		s.times = append(s.times, time.Time{})
+		copy(s.times[i+1:], s.times[i:])
+		s.times[i] = snapshot.Time
+	}
+	s.prune(time.Now())
+	return nil
+}
+
+// At returns the most recent snapshot taken at or before t
+func (s *Store) At(t time.Time) (models.Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := sort.Search(len(s.times), func(i int) bool { return s.times[i].After(t) })
+	if i == 0 {
+		return models.Snapshot{}, ErrNotFound
+	}
+	return s.read(s.times[i-1])
+}
+
+// After returns the oldest snapshot taken at or after t
+func (s *Store) After(t time.Time) (models.Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := sort.Search(len(s.times), func(i int) bool { return !s.times[i].Before(t) })
+	if i == len(s.times) {
+		return models.Snapshot{}, ErrNotFound
+	}
+	return s.read(s.times[i])
+}
+
+// Len returns the number of stored snapshots
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.times)
+}
+
+func (s *Store) read(t time.Time) (models.Snapshot, error) {
+	var snapshot models.Snapshot
+	body, err := ioutil.ReadFile(s.path(t))
+	if err != nil {
+		return snapshot, err
+	}
+	err = json.Unmarshal(body, &snapshot)
+	return snapshot, err
+}
+
+// prune removes the snapshots outside of the retention policy, s.mu must be held
+func (s *Store) prune(now time.Time) {
+	remove := 0
+	if s.MaxCount > 0 && len(s.times) > s.MaxCount {
+		remove = len(s.times) - s.MaxCount
+	}
+	if s.MaxAge > 0 {
+		for remove < len(s.times) && now.Sub(s.times[remove]) > s.MaxAge {
+			remove++
+		}
+	}
+	for _, t := range s.times[:remove] {
+		if err := os.Remove(s.path(t)); err != nil && !os.IsNotExist(err) {
+			log.Println("Error while removing snapshot: ", err)
+		}
+	}
+	s.times = s.times[remove:]
+}
+
+func (s *Store) path(t time.Time) string {
+	return filepath.Join(s.Dir, strconv.FormatInt(t.UnixNano(), 10)+ext)
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"assignment/models"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func snapshotAt(t time.Time, views int) models.Snapshot {
+	return models.Snapshot{
+		Time: t,
+		UrlData: []models.UrlData{
+			{
+				Url:            "www.example.com/abc1",
+				Views:          views,
+				RelevanceScore: 0.1,
+			},
+		},
+	}
+}
+
+func TestAtAndAfter(t *testing.T) {
+	s, err := Open(t.TempDir(), 0, 0)
+	assert.Nil(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	assert.Nil(t, s.Save(snapshotAt(now.Add(-2*time.Hour), 100)))
+	assert.Nil(t, s.Save(snapshotAt(now.Add(-1*time.Hour), 200)))
+	assert.Nil(t, s.Save(snapshotAt(now, 300)))
+
+	snapshot, err := s.At(now.Add(-90 * time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, 100, snapshot.UrlData[0].Views)
+	assert.True(t, snapshot.Time.Equal(now.Add(-2*time.Hour)))
+
+	snapshot, err = s.At(now.Add(-1 * time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, 200, snapshot.UrlData[0].Views)
+
+	snapshot, err = s.At(now.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, 300, snapshot.UrlData[0].Views)
+
+	_, err = s.At(now.Add(-3 * time.Hour))
+	assert.Equal(t, ErrNotFound, err)
+
+	snapshot, err = s.After(now.Add(-90 * time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, 200, snapshot.UrlData[0].Views)
+
+	snapshot, err = s.After(now)
+	assert.Nil(t, err)
+	assert.Equal(t, 300, snapshot.UrlData[0].Views)
+
+	_, err = s.After(now.Add(time.Second))
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestOpenExisting(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 0, 0)
+	assert.Nil(t, err)
+
+	now := time.Now().UTC()
+	assert.Nil(t, s.Save(snapshotAt(now, 100)))
+	assert.Nil(t, ioutil.WriteFile(dir+"/unknown.json", []byte("{}"), 0644))
+
+	s, err = Open(dir, 0, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, s.Len())
+	snapshot, err := s.At(now)
+	assert.Nil(t, err)
+	assert.Equal(t, 100, snapshot.UrlData[0].Views)
+}
+
+func TestOpenRemovesTemporaryFiles(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "tmp-123"), []byte("{"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "tmp-456.json"), []byte("{"), 0644))
+
+	s, err := Open(dir, 0, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, s.Len())
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Empty(t, files)
+}
+
+func TestRetention(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 24*time.Hour, 3)
+	assert.Nil(t, err)
+
+	now := time.Now().UTC()
+	assert.Nil(t, s.Save(snapshotAt(now.Add(-48*time.Hour), 100)))
+	assert.Equal(t, 0, s.Len())
+
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, s.Save(snapshotAt(now.Add(time.Duration(i)*time.Minute), i)))
+	}
+	assert.Equal(t, 3, s.Len())
+
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(files))
+
+	_, err = s.At(now.Add(time.Minute))
+	assert.Equal(t, ErrNotFound, err)
+	snapshot, err := s.At(now.Add(2 * time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, snapshot.UrlData[0].Views)
+}
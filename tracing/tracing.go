@@ -0,0 +1,117 @@
+// Package tracing configures the OpenTelemetry tracer provider of the server.
+// Spans are exported to stdout or to an OTLP/HTTP collector, and the W3C trace
+// context is propagated to and from the server.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+)
+
+// Exporters of the spans
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// serviceName is the default service.name of the spans, OTEL_SERVICE_NAME overrides it
+const serviceName = "assignment"
+
+// Options configures the export of the spans
+type Options struct {
+	// Exporter is none, stdout or otlp
+	Exporter string
+	// Endpoint is the url of the OTLP/HTTP collector, by default OTEL_EXPORTER_OTLP_ENDPOINT
+	// or https://localhost:4318
+	Endpoint string
+	// SampleRatio is the fraction of the traces started by the server that are sampled
+	SampleRatio float64
+	// Output receives the spans of the stdout exporter, by default os.Stdout
+	Output io.Writer
+}
+
+// Check validates the exporter and the sample ratio of the options
+func (o Options) Check() error {
+	switch o.Exporter {
+	case ExporterNone, ExporterStdout, ExporterOTLP:
+	default:
+		return errors.New("unsupported trace exporter: " + o.Exporter)
+	}
+	if o.SampleRatio < 0 || o.SampleRatio > 1 {
+		return errors.New("trace sample ratio must be between 0 and 1")
+	}
+	if o.Endpoint != "" {
+		u, err := url.Parse(o.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("invalid trace endpoint: " + o.Endpoint)
+		}
+	}
+	return nil
+}
+
+// Setup installs the global tracer provider and the W3C trace context
+// propagator. The returned function flushes the pending spans and stops the
+// exporter. With the none exporter the spans are dropped and no context is
+// propagated.
+func Setup(ctx context.Context, options Options) (func(context.Context) error, error) {
+	if err := options.Check(); err != nil {
+		return nil, err
+	}
+	if options.Exporter == ExporterNone {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := newExporter(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceNameKey.String(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// callers decide whether the traces they started are sampled
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(options.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, options Options) (sdktrace.SpanExporter, error) {
+	if options.Exporter == ExporterStdout {
+		if options.Output == nil {
+			return stdouttrace.New()
+		}
+		return stdouttrace.New(stdouttrace.WithWriter(options.Output))
+	}
+	var clientOptions []otlptracehttp.Option
+	if options.Endpoint != "" {
+		u, _ := url.Parse(options.Endpoint)
+		clientOptions = append(clientOptions, otlptracehttp.WithEndpoint(u.Host))
+		if u.Scheme == "http" {
+			clientOptions = append(clientOptions, otlptracehttp.WithInsecure())
+		}
+		if u.Path != "" && u.Path != "/" {
+			clientOptions = append(clientOptions, otlptracehttp.WithURLPath(u.Path))
+		}
+	}
+	return otlptracehttp.New(ctx, clientOptions...)
+}
@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// resetGlobals restores the no-op tracer provider and propagator after a test
+func resetGlobals(t *testing.T) {
+	t.Cleanup(func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		options Options
+		err     string
+	}{
+		{Options{Exporter: ExporterNone}, ""},
+		{Options{Exporter: ExporterOTLP, Endpoint: "http://collector:4318", SampleRatio: 0.5}, ""},
+		{Options{Exporter: "jaeger"}, "unsupported trace exporter: jaeger"},
+		{Options{Exporter: ExporterStdout, SampleRatio: -1}, "trace sample ratio must be between 0 and 1"},
+		{Options{Exporter: ExporterOTLP, Endpoint: "collector:4318"}, "invalid trace endpoint: collector:4318"},
+	}
+	for _, tt := range tests {
+		err := tt.options.Check()
+		if tt.err == "" {
+			assert.Nil(t, err)
+		} else {
+			assert.EqualError(t, err, tt.err)
+		}
+	}
+}
+
+func TestSetupNone(t *testing.T) {
+	resetGlobals(t)
+	shutdown, err := Setup(context.Background(), Options{Exporter: ExporterNone})
+	assert.Nil(t, err)
+	_, span := otel.Tracer("test").Start(context.Background(), "dropped")
+	assert.False(t, span.SpanContext().IsValid())
+	span.End()
+	assert.Nil(t, shutdown(context.Background()))
+}
+
+func TestSetupStdout(t *testing.T) {
+	resetGlobals(t)
+	var buf bytes.Buffer
+	shutdown, err := Setup(context.Background(), Options{Exporter: ExporterStdout, SampleRatio: 1, Output: &buf})
+	assert.Nil(t, err)
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "stdout-span")
+	header := http.Header{}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+	span.End()
+	assert.Nil(t, shutdown(context.Background()))
+
+	assert.Contains(t, buf.String(), `"Name":"stdout-span"`)
+	assert.Contains(t, buf.String(), `{"Key":"service.name","Value":{"Type":"STRING","Value":"assignment"}}`)
+	assert.Contains(t, header.Get("traceparent"), span.SpanContext().TraceID().String())
+}
+
+func TestSetupOTLP(t *testing.T) {
+	resetGlobals(t)
+	var exported int32
+	collector := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method == "POST" && req.URL.Path == "/v1/traces" {
+			atomic.AddInt32(&exported, 1)
+		}
+	}))
+	defer collector.Close()
+
+	shutdown, err := Setup(context.Background(), Options{Exporter: ExporterOTLP, Endpoint: collector.URL, SampleRatio: 1})
+	assert.Nil(t, err)
+	_, span := otel.Tracer("test").Start(context.Background(), "otlp-span")
+	span.End()
+	assert.Nil(t, shutdown(context.Background()))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&exported))
+}
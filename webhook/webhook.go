@@ -0,0 +1,399 @@
+package webhook
+
+import (
+	"assignment/models"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	retries     = 3  // default max delivery attempts
+	maxAttempts = 20 // attempts kept in the history of a webhook
+	maxN        = 200
+	maxHooks    = 100
+
+	// SignatureHeader carries the HMAC-SHA256 of the payload when a secret is set
+	SignatureHeader = "X-Signature-256"
+)
+
+// Webhook is a receiver notified when the top-N ranking for SortKey changes
+type Webhook struct {
+	ID       string    `json:"id"`
+	URL      string    `json:"url"`
+	SortKey  string    `json:"sortKey"`
+	N        int       `json:"n"`
+	Secret   string    `json:"secret,omitempty"`
+	Attempts []Attempt `json:"attempts"`
+
+	top  []models.UrlData // top-N seen on the last notification
+	seen bool
+}
+
+// Attempt is one delivery attempt of a payload to a webhook
+type Attempt struct {
+	Time       time.Time `json:"time"`
+	Delivery   int       `json:"delivery"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Change describes how a single url moved in the top-N ranking.
+// Ranks are 1-based, 0 means the url was not in the top-N.
+type Change struct {
+	Url     string `json:"url"`
+	Type    string `json:"type"`
+	OldRank int    `json:"oldRank,omitempty"`
+	NewRank int    `json:"newRank,omitempty"`
+}
+
+// Payload is the JSON body posted to a webhook
+type Payload struct {
+	WebhookID string           `json:"webhookId"`
+	Delivery  int              `json:"delivery"`
+	SortKey   string           `json:"sortKey"`
+	N         int              `json:"n"`
+	Time      time.Time        `json:"time"`
+	Top       []models.UrlData `json:"top"`
+	Changes   []Change         `json:"changes"`
+}
+
+// Registry holds the registered webhooks and delivers their notifications
+type Registry struct {
+	Client  *http.Client
+	Retries int
+	Backoff time.Duration
+	// MaxN is the largest N of a webhook, 0 for no limit
+	MaxN int
+	// MaxHooks is the largest number of webhooks, 0 for no limit
+	MaxHooks int
+	// Hosts are the only hosts webhooks can be registered for, any host when empty
+	Hosts []string
+	// AllowPrivate allows delivering to loopback, link-local and private
+	// addresses, which are otherwise only allowed for the listed Hosts
+	AllowPrivate bool
+
+	mu         sync.Mutex
+	hooks      map[string]*Webhook
+	nextID     int
+	deliveries int
+	wg         sync.WaitGroup
+	closed     bool
+	ctx        context.Context // canceled to abort the deliveries on Close
+	cancel     context.CancelFunc
+}
+
+// NewRegistry returns an empty registry with the default delivery settings
+func NewRegistry() *Registry {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Registry{
+		Retries:  retries,
+		Backoff:  2 * time.Second,
+		MaxN:     maxN,
+		MaxHooks: maxHooks,
+		hooks:    make(map[string]*Webhook),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	r.Client = &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &http.Transport{DialContext: r.dialContext},
+	}
+	return r
+}
+
+// Register validates and adds a webhook, returning it with its assigned ID
+func (r *Registry) Register(hook Webhook) (Webhook, error) {
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Webhook{}, errors.New("webhook 'url' must be an absolute http(s) url")
+	}
+	if len(r.Hosts) > 0 && !r.listed(u.Hostname()) {
+		return Webhook{}, errors.New("webhook 'url' host is not allowed: " + u.Hostname())
+	}
+	if !r.allowed(u.Hostname()) && (u.Hostname() == "localhost" || isPrivate(net.ParseIP(u.Hostname()))) {
+		return Webhook{}, errors.New("webhook 'url' address is private: " + u.Hostname())
+	}
+	if hook.SortKey != "relevanceScore" && hook.SortKey != "views" {
+		return Webhook{}, errors.New("webhook 'sortKey' is invalid")
+	}
+	if hook.N < 1 || (r.MaxN > 0 && hook.N > r.MaxN) {
+		return Webhook{}, errors.New("webhook 'n' is invalid")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.MaxHooks > 0 && len(r.hooks) >= r.MaxHooks {
+		return Webhook{}, errors.New("too many webhooks, at most " + strconv.Itoa(r.MaxHooks))
+	}
+	r.nextID++
+	hook.ID = strconv.Itoa(r.nextID)
+	hook.Attempts = nil
+	hook.top = nil
+	hook.seen = false
+	r.hooks[hook.ID] = &hook
+	return hook.public(), nil
+}
+
+// Remove deletes the webhook with id and reports whether it existed
+func (r *Registry) Remove(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.hooks[id]
+	delete(r.hooks, id)
+	return ok
+}
+
+// List returns all webhooks with their attempt history, without secrets
+func (r *Registry) List() []Webhook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hooks := make([]Webhook, 0, len(r.hooks))
+	for _, hook := range r.hooks {
+		hooks = append(hooks, hook.public())
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		a, _ := strconv.Atoi(hooks[i].ID)
+		b, _ := strconv.Atoi(hooks[j].ID)
+		return a < b
+	})
+	return hooks
+}
+
+// Notify computes the top-N of data for every webhook and delivers a payload
+// in the background to those whose ranking changed since the last call
+func (r *Registry) Notify(data []models.UrlData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	for _, hook := range r.hooks {
+		top := Top(data, hook.SortKey, hook.N)
+		changes := diff(hook.top, top)
+		if hook.seen && len(changes) == 0 {
+			continue
+		}
+		hook.top = top
+		hook.seen = true
+
+		r.deliveries++
+		payload := Payload{
+			WebhookID: hook.ID,
+			Delivery:  r.deliveries,
+			SortKey:   hook.SortKey,
+			N:         hook.N,
+			Time:      time.Now().UTC(),
+			Top:       top,
+			Changes:   changes,
+		}
+		r.wg.Add(1)
+		go r.deliver(*hook, payload)
+	}
+}
+
+// Wait blocks until all in-flight deliveries are finished
+func (r *Registry) Wait() {
+	r.wg.Wait()
+}
+
+// Close stops notifying the webhooks and waits for the in-flight deliveries
+// until ctx is done, then aborts those still running
+func (r *Registry) Close(ctx context.Context) error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	defer r.cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		r.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// deliver posts the payload to the webhook, retrying with backoff on failure
+func (r *Registry) deliver(hook Webhook, payload Payload) {
+	defer r.wg.Done()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Println("Error happened in JSON marshal: ", err)
+		return
+	}
+
+	sleep := r.Backoff
+	for i := 0; i < r.Retries; i++ {
+		if i > 0 {
+			select {
+			case <-r.ctx.Done():
+				return
+			case <-time.After(sleep):
+			}
+			sleep *= 2
+		}
+		attempt := Attempt{Time: time.Now().UTC(), Delivery: payload.Delivery, Attempt: i + 1}
+		attempt.StatusCode, err = r.post(hook, body)
+		if err != nil {
+			attempt.Error = err.Error()
+			log.Println("Error while delivering webhook: ", hook.URL, err)
+		}
+		r.record(hook.ID, attempt)
+		if err == nil {
+			return
+		}
+	}
+}
+
+func (r *Registry) post(hook Webhook, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if hook.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(hook.Secret, body))
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("status code %d from: %s", resp.StatusCode, hook.URL)
+	}
+	return resp.StatusCode, nil
+}
+
+// listed reports whether host is one of the Hosts
+func (r *Registry) listed(host string) bool {
+	for _, h := range r.Hosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed reports whether host may have a private address
+func (r *Registry) allowed(host string) bool {
+	return r.AllowPrivate || r.listed(host)
+}
+
+// dialContext connects to the receiver of a webhook. The name of the
+// receiver is resolved here so that it cannot lead to a private address,
+// whatever it resolved to when the webhook was registered.
+func (r *Registry) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var dialer net.Dialer
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if r.allowed(host) {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, errors.New("no address for webhook host: " + host)
+	}
+	for _, ip := range ips {
+		if isPrivate(ip.IP) {
+			return nil, errors.New("webhook address is private: " + host)
+		}
+	}
+	return dialer.DialContext(ctx, network, net.JoinHostPort(ips[0].IP.String(), port))
+}
+
+// isPrivate reports whether ip is a loopback, link-local, private or unspecified address
+func isPrivate(ip net.IP) bool {
+	return ip != nil && (ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsPrivate() || ip.IsUnspecified())
+}
+
+func (r *Registry) record(id string, attempt Attempt) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hook, ok := r.hooks[id]
+	if !ok {
+		return
+	}
+	hook.Attempts = append(hook.Attempts, attempt)
+	if len(hook.Attempts) > maxAttempts {
+		hook.Attempts = hook.Attempts[len(hook.Attempts)-maxAttempts:]
+	}
+}
+
+func (hook Webhook) public() Webhook {
+	hook.Secret = ""
+	hook.Attempts = append([]Attempt(nil), hook.Attempts...)
+	return hook
+}
+
+// Sign returns the signature header value for body: "sha256=" followed by
+// the hex encoded HMAC-SHA256 of body keyed with secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Top returns the first n items of data ranked by key, in the order of getData
+func Top(data []models.UrlData, key string, n int) []models.UrlData {
+	top := append([]models.UrlData(nil), data...)
+	models.SortByKey(top, key)
+	if n < len(top) {
+		top = top[:n]
+	}
+	return top
+}
+
+// diff lists the urls entering, leaving or moving between two rankings
+func diff(old, new []models.UrlData) []Change {
+	oldRanks := make(map[string]int, len(old))
+	for i, data := range old {
+		oldRanks[data.Url] = i + 1
+	}
+	var changes []Change
+	newRanks := make(map[string]int, len(new))
+	for i, data := range new {
+		newRanks[data.Url] = i + 1
+		oldRank, ok := oldRanks[data.Url]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Url: data.Url, Type: "entered", NewRank: i + 1})
+		case oldRank != i+1:
+			changes = append(changes, Change{Url: data.Url, Type: "moved", OldRank: oldRank, NewRank: i + 1})
+		}
+	}
+	for i, data := range old {
+		if _, ok := newRanks[data.Url]; !ok {
+			changes = append(changes, Change{Url: data.Url, Type: "left", OldRank: i + 1})
+		}
+	}
+	return changes
+}
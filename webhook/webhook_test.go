@@ -0,0 +1,249 @@
+package webhook
+
+import (
+	"assignment/models"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testData = []models.UrlData{
+	{Url: "www.example.com/abc1", Views: 1000, RelevanceScore: 0.4},
+	{Url: "www.example.com/abc2", Views: 2000, RelevanceScore: 0.5},
+	{Url: "www.example.com/abc3", Views: 3000, RelevanceScore: 0.1},
+}
+
+type receiver struct {
+	mu       sync.Mutex
+	payloads []Payload
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func (rc *receiver) handler(status int) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		var payload Payload
+		json.Unmarshal(body, &payload)
+		rc.mu.Lock()
+		rc.payloads = append(rc.payloads, payload)
+		rc.bodies = append(rc.bodies, body)
+		rc.headers = append(rc.headers, req.Header)
+		rc.mu.Unlock()
+		rw.WriteHeader(status)
+	}
+}
+
+func newTestRegistry() *Registry {
+	r := NewRegistry()
+	r.Backoff = time.Millisecond
+	// the test receivers listen on loopback
+	r.AllowPrivate = true
+	return r
+}
+
+func TestNotifySignedPayload(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc.handler(http.StatusOK))
+	defer server.Close()
+
+	r := newTestRegistry()
+	hook, err := r.Register(Webhook{URL: server.URL, SortKey: "views", N: 2, Secret: "s3cr3t"})
+	assert.Nil(t, err)
+	assert.Equal(t, "", hook.Secret)
+
+	r.Notify(testData)
+	r.Wait()
+
+	assert.Equal(t, 1, len(rc.payloads))
+	payload := rc.payloads[0]
+	assert.Equal(t, hook.ID, payload.WebhookID)
+	// ranked in the ascending order of getData
+	assert.Equal(t, "www.example.com/abc1", payload.Top[0].Url)
+	assert.Equal(t, "www.example.com/abc2", payload.Top[1].Url)
+	assert.Equal(t, 2, len(payload.Changes))
+	assert.Equal(t, "entered", payload.Changes[0].Type)
+	assert.Equal(t, Sign("s3cr3t", rc.bodies[0]), rc.headers[0].Get(SignatureHeader))
+
+	hooks := r.List()
+	assert.Equal(t, 1, len(hooks[0].Attempts))
+	assert.Equal(t, http.StatusOK, hooks[0].Attempts[0].StatusCode)
+}
+
+func TestNotifyOnlyWhenRankingChanges(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc.handler(http.StatusOK))
+	defer server.Close()
+
+	r := newTestRegistry()
+	_, err := r.Register(Webhook{URL: server.URL, SortKey: "views", N: 2})
+	assert.Nil(t, err)
+
+	r.Notify(testData)
+	r.Wait()
+	r.Notify(testData)
+	r.Wait()
+	assert.Equal(t, 1, len(rc.payloads))
+	assert.Equal(t, "", rc.headers[0].Get(SignatureHeader))
+
+	changed := append([]models.UrlData(nil), testData...)
+	changed[2].Views = 500
+	r.Notify(changed)
+	r.Wait()
+
+	assert.Equal(t, 2, len(rc.payloads))
+	assert.Equal(t, []Change{
+		{Url: "www.example.com/abc3", Type: "entered", NewRank: 1},
+		{Url: "www.example.com/abc1", Type: "moved", OldRank: 1, NewRank: 2},
+		{Url: "www.example.com/abc2", Type: "left", OldRank: 2},
+	}, rc.payloads[1].Changes)
+}
+
+func TestNotifyRetry(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc.handler(http.StatusInternalServerError))
+	defer server.Close()
+
+	r := newTestRegistry()
+	_, err := r.Register(Webhook{URL: server.URL, SortKey: "relevanceScore", N: 1})
+	assert.Nil(t, err)
+
+	r.Notify(testData)
+	r.Wait()
+
+	assert.Equal(t, retries, len(rc.payloads))
+	attempts := r.List()[0].Attempts
+	assert.Equal(t, retries, len(attempts))
+	for i, attempt := range attempts {
+		assert.Equal(t, i+1, attempt.Attempt)
+		assert.Equal(t, http.StatusInternalServerError, attempt.StatusCode)
+		assert.NotEqual(t, "", attempt.Error)
+	}
+}
+
+func TestRegisterInvalid(t *testing.T) {
+	r := newTestRegistry()
+	tests := []Webhook{
+		{URL: "not a url", SortKey: "views", N: 1},
+		{URL: "ftp://example.com", SortKey: "views", N: 1},
+		{URL: "http://example.com", SortKey: "abc", N: 1},
+		{URL: "http://example.com", SortKey: "views", N: 0},
+		{URL: "http://example.com", SortKey: "views", N: 500},
+	}
+	for _, hook := range tests {
+		_, err := r.Register(hook)
+		assert.NotNil(t, err)
+	}
+	assert.Equal(t, 0, len(r.List()))
+}
+
+func TestRegisterLimits(t *testing.T) {
+	r := newTestRegistry()
+	r.MaxHooks = 1
+	_, err := r.Register(Webhook{URL: "http://example.com", SortKey: "views", N: 1})
+	assert.Nil(t, err)
+	_, err = r.Register(Webhook{URL: "http://example.com", SortKey: "views", N: 1})
+	assert.EqualError(t, err, "too many webhooks, at most 1")
+
+	r = newTestRegistry()
+	r.Hosts = []string{"receiver.example.com"}
+	_, err = r.Register(Webhook{URL: "http://example.com", SortKey: "views", N: 1})
+	assert.EqualError(t, err, "webhook 'url' host is not allowed: example.com")
+	_, err = r.Register(Webhook{URL: "https://Receiver.example.com/hook", SortKey: "views", N: 1})
+	assert.Nil(t, err)
+}
+
+func TestRegisterPrivate(t *testing.T) {
+	r := NewRegistry()
+	for _, rawURL := range []string{
+		"http://localhost:8080/hook",
+		"http://127.0.0.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.1/hook",
+		"http://[::1]/hook",
+	} {
+		_, err := r.Register(Webhook{URL: rawURL, SortKey: "views", N: 1})
+		assert.NotNil(t, err, rawURL)
+	}
+
+	// unless the host is listed
+	r.Hosts = []string{"10.0.0.1"}
+	_, err := r.Register(Webhook{URL: "http://10.0.0.1/hook", SortKey: "views", N: 1})
+	assert.Nil(t, err)
+}
+
+func TestDeliverPrivateRefused(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc.handler(http.StatusOK))
+	defer server.Close()
+
+	// a name resolving to a private address is refused when delivering, as
+	// when it resolved to another address at registration
+	r := newTestRegistry()
+	r.Retries = 1
+	_, err := r.Register(Webhook{URL: strings.Replace(server.URL, "127.0.0.1", "localhost", 1), SortKey: "views", N: 2})
+	assert.Nil(t, err)
+	r.AllowPrivate = false
+	r.Notify(testData)
+	r.Wait()
+
+	assert.Equal(t, 0, len(rc.payloads))
+	attempts := r.List()[0].Attempts
+	assert.Equal(t, 1, len(attempts))
+	assert.Contains(t, attempts[0].Error, "webhook address is private: localhost")
+}
+
+func TestRemove(t *testing.T) {
+	r := newTestRegistry()
+	hook, err := r.Register(Webhook{URL: "http://example.com", SortKey: "views", N: 1})
+	assert.Nil(t, err)
+	assert.True(t, r.Remove(hook.ID))
+	assert.False(t, r.Remove(hook.ID))
+	assert.Equal(t, 0, len(r.List()))
+}
+
+func TestCloseWaitsForDeliveries(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc.handler(http.StatusOK))
+	defer server.Close()
+
+	r := newTestRegistry()
+	r.Register(Webhook{URL: server.URL, SortKey: "views", N: 2})
+	r.Notify(testData)
+	assert.Nil(t, r.Close(context.Background()))
+	assert.Equal(t, 1, len(rc.payloads))
+
+	// no more notifications after Close
+	r.Notify(testData[:1])
+	r.Wait()
+	assert.Equal(t, 1, len(rc.payloads))
+}
+
+func TestCloseAbortsRetries(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc.handler(http.StatusInternalServerError))
+	defer server.Close()
+
+	r := NewRegistry()
+	r.Backoff = time.Hour
+	r.AllowPrivate = true
+	r.Register(Webhook{URL: server.URL, SortKey: "views", N: 2})
+	r.Notify(testData)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.Equal(t, context.DeadlineExceeded, r.Close(ctx))
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	rc.mu.Lock()
+	assert.Equal(t, 1, len(rc.payloads))
+	rc.mu.Unlock()
+}